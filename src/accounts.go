@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// accountsDir is where accounts lists live, as <list>.json registries or plain <list>.txt files.
+// It's a variable so that tests can point it at a temporary directory.
+var accountsDir = "./data"
+
+// Account is an entry of an accounts registry. Only Handle is required.
+type Account struct {
+	Handle      string   `json:"handle"`
+	DisplayName string   `json:"display_name,omitempty"`
+	Category    string   `json:"category,omitempty"` // e.g. crypto-agent, corporate-agent, meme
+	Operator    string   `json:"operator,omitempty"` // who runs the account, if known
+	Model       string   `json:"model,omitempty"`    // the underlying model, if known
+	Tags        []string `json:"tags,omitempty"`
+	ActiveFrom  string   `json:"active_from,omitempty"` // YYYY-MM-DD; tweets before it are left out
+	ActiveTo    string   `json:"active_to,omitempty"`   // YYYY-MM-DD; tweets after it are left out
+}
+
+// accountsRegistry is the format of <list>.json
+type accountsRegistry struct {
+	Description string    `json:"description,omitempty"`
+	Accounts    []Account `json:"accounts"`
+}
+
+// loadAccounts reads an accounts list: data/<list>.json if there is one, and otherwise
+// data/<list>.txt, with one handle per line and # comments
+func loadAccounts(accountsList string) ([]Account, error) {
+	data, err := os.ReadFile(filepath.Join(accountsDir, accountsList+".json"))
+	if err == nil {
+		var registry accountsRegistry
+		if err := json.Unmarshal(data, &registry); err != nil {
+			return []Account{}, fmt.Errorf("error parsing accounts file %s.json: %v", accountsList, err)
+		}
+		accounts := registry.Accounts[:0]
+		for _, account := range registry.Accounts {
+			if account.Handle = normalizeHandle(account.Handle); account.Handle == "" {
+				continue
+			}
+			for _, date := range []string{account.ActiveFrom, account.ActiveTo} {
+				if _, err := time.Parse(dateLayout, date); date != "" && err != nil {
+					return []Account{}, fmt.Errorf("accounts file %s.json: invalid date %q for @%s", accountsList, date, account.Handle)
+				}
+			}
+			accounts = append(accounts, account)
+		}
+		return accounts, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return []Account{}, fmt.Errorf("error reading accounts file: %v", err)
+	}
+
+	data, err = os.ReadFile(filepath.Join(accountsDir, accountsList+".txt"))
+	if err != nil {
+		return []Account{}, fmt.Errorf("error reading accounts file: %v", err)
+	}
+	var accounts []Account
+	for _, line := range strings.Split(string(data), "\n") {
+		// Filter out empty and commented accounts
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		accounts = append(accounts, Account{Handle: normalizeHandle(line)})
+	}
+	return accounts, nil
+}
+
+// normalizeHandle trims whitespace and a leading @
+func normalizeHandle(handle string) string {
+	return strings.TrimPrefix(strings.TrimSpace(handle), "@")
+}
+
+// getAccounts returns the handles in an accounts list
+func getAccounts(accountsList string) ([]string, error) {
+	accounts, err := loadAccounts(accountsList)
+	if err != nil {
+		return []string{}, err
+	}
+	return accountHandles(accounts), nil
+}
+
+func accountHandles(accounts []Account) []string {
+	handles := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		handles = append(handles, account.Handle)
+	}
+	return handles
+}
+
+// listAccountsLists returns the names of the account lists available in ./data
+func listAccountsLists() ([]string, error) {
+	var lists []string
+	for _, ext := range []string{".json", ".txt"} {
+		paths, err := filepath.Glob(filepath.Join(accountsDir, "*"+ext))
+		if err != nil {
+			return []string{}, fmt.Errorf("error listing accounts files: %v", err)
+		}
+		for _, path := range paths {
+			if list := strings.TrimSuffix(filepath.Base(path), ext); !slices.Contains(lists, list) {
+				lists = append(lists, list)
+			}
+		}
+	}
+	sort.Strings(lists)
+	return lists, nil
+}
+
+// AccountFilter selects accounts of a list by their metadata. Empty fields don't filter;
+// an account must match every non-empty field, and one of the values given for it.
+type AccountFilter struct {
+	Categories []string
+	Operators  []string
+	Tags       []string
+}
+
+func (f AccountFilter) IsEmpty() bool {
+	return len(f.Categories) == 0 && len(f.Operators) == 0 && len(f.Tags) == 0
+}
+
+func (f AccountFilter) Matches(account Account) bool {
+	if len(f.Categories) > 0 && !containsFold(f.Categories, account.Category) {
+		return false
+	}
+	if len(f.Operators) > 0 && !containsFold(f.Operators, account.Operator) {
+		return false
+	}
+	if len(f.Tags) > 0 && !slices.ContainsFunc(account.Tags, func(tag string) bool { return containsFold(f.Tags, tag) }) {
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, value) })
+}
+
+// String describes the filter, e.g. "category=meme tag=defi,nft"
+func (f AccountFilter) String() string {
+	var parts []string
+	for _, field := range []struct {
+		name   string
+		values []string
+	}{{"category", f.Categories}, {"operator", f.Operators}, {"tag", f.Tags}} {
+		if len(field.values) > 0 {
+			parts = append(parts, field.name+"="+strings.Join(field.values, ","))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// reportName names the reports on accountsList, with the filter if there is one, so that
+// filtered reports don't overwrite the full ones
+func (a *App) reportName(accountsList string) string {
+	if a.accountFilter.IsEmpty() {
+		return accountsList
+	}
+	slug := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, a.accountFilter.String())
+	return accountsList + "_" + slug
+}
+
+// describe is a one-line description of the account, for the accounts command
+func (account Account) describe() string {
+	parts := []string{account.Handle}
+	if account.DisplayName != "" {
+		parts = append(parts, fmt.Sprintf("(%s)", account.DisplayName))
+	}
+	for _, field := range []struct{ name, value string }{
+		{"category", account.Category},
+		{"operator", account.Operator},
+		{"model", account.Model},
+		{"tags", strings.Join(account.Tags, ",")},
+		{"active", activeRange(account.ActiveFrom, account.ActiveTo)},
+	} {
+		if field.value != "" {
+			parts = append(parts, field.name+"="+field.value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func activeRange(from string, to string) string {
+	if from == "" && to == "" {
+		return ""
+	}
+	return from + ".." + to
+}
+
+// ActiveOn tells whether the account was active on the day of date
+func (account Account) ActiveOn(date time.Time) bool {
+	day := date.Format(dateLayout)
+	if account.ActiveFrom != "" && day < account.ActiveFrom {
+		return false
+	}
+	if account.ActiveTo != "" && day > account.ActiveTo {
+		return false
+	}
+	return true
+}
+
+// selectAccounts returns the accounts of the list that match the App's filter, and if day
+// isn't zero, that were active on it
+func (a *App) selectAccounts(accountsList string, day time.Time) ([]Account, error) {
+	accounts, err := loadAccounts(accountsList)
+	if err != nil {
+		return []Account{}, err
+	}
+	var selected []Account
+	for _, account := range accounts {
+		if a.accountFilter.Matches(account) && (day.IsZero() || account.ActiveOn(day)) {
+			selected = append(selected, account)
+		}
+	}
+	return selected, nil
+}
+
+// accountGroupKeys are the fields reports can group accounts by
+var accountGroupKeys = []string{"category", "operator", "model", "tag"}
+
+// groupNames returns the groups of account when grouping by key. Accounts without a
+// value are in the group "other"; with tags, an account can be in several groups.
+func (account Account) groupNames(key string) []string {
+	var names []string
+	switch key {
+	case "category":
+		names = []string{account.Category}
+	case "operator":
+		names = []string{account.Operator}
+	case "model":
+		names = []string{account.Model}
+	case "tag":
+		names = account.Tags
+	}
+	names = slices.DeleteFunc(slices.Clone(names), func(name string) bool { return name == "" })
+	if len(names) == 0 {
+		return []string{"other"}
+	}
+	return names
+}
+
+// AccountGroup is a group of accounts in a report, and what they tweeted
+type AccountGroup struct {
+	Name       string   `json:"name"`
+	Accounts   []string `json:"accounts"`
+	TweetCount int      `json:"tweet_count"`
+}
+
+// groupAccounts groups the accounts of dailyReports by key, using the metadata in accounts
+func groupAccounts(key string, accounts []Account, dailyReports []DailyReport) []AccountGroup {
+	byHandle := make(map[string]Account)
+	for _, account := range accounts {
+		byHandle[account.Handle] = account
+	}
+
+	groups := make(map[string]*AccountGroup)
+	for _, dailyReport := range dailyReports {
+		for _, accountReport := range dailyReport.AccountReports {
+			account, ok := byHandle[accountReport.Username]
+			if !ok {
+				account = Account{Handle: accountReport.Username}
+			}
+			for _, name := range account.groupNames(key) {
+				group, ok := groups[name]
+				if !ok {
+					group = &AccountGroup{Name: name}
+					groups[name] = group
+				}
+				if !slices.Contains(group.Accounts, account.Handle) {
+					group.Accounts = append(group.Accounts, account.Handle)
+				}
+				group.TweetCount += accountReport.TweetCount
+			}
+		}
+	}
+
+	sorted := make([]AccountGroup, 0, len(groups))
+	for _, group := range groups {
+		sort.Strings(group.Accounts)
+		sorted = append(sorted, *group)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
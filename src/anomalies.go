@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Alert severities, from least to most urgent
+const (
+	severityInfo     = "info"
+	severityWarning  = "warning"
+	severityCritical = "critical"
+)
+
+// severityRank orders severities, so alerts can be compared against a threshold
+var severityRank = map[string]int{severityInfo: 0, severityWarning: 1, severityCritical: 2}
+
+// Kinds of alerts
+const (
+	alertSilent        = "silent"         // no tweets on a day the account usually tweets
+	alertBurst         = "burst"          // far more tweets than usual on a day
+	alertDrop          = "drop"           // far fewer tweets than usual on a day
+	alertHourlyBurst   = "hourly_burst"   // more tweets in one hour than in any hour of the baseline
+	alertScheduleShift = "schedule_shift" // tweeting at different hours than usual
+	alertReplyRatio    = "reply_ratio"    // a different share of replies than usual
+)
+
+// Thresholds of anomaly detection. Daily counts are compared to the baseline with a
+// z-score; the standard deviation is at least that of a Poisson count with the same mean,
+// so quiet accounts don't raise alerts for a couple of extra tweets.
+const (
+	minBaselineDays        = 7    // days of history an account needs before it's checked
+	burstZScore            = 3.0  // z-score of a burst or drop
+	criticalZScore         = 5.0  // z-score at which a burst or drop is critical
+	silentMinMean          = 3.0  // tweets per day below which a silent day isn't unusual
+	silentCriticalMean     = 10.0 // tweets per day above which a silent day is critical
+	hourlyBurstMin         = 10   // tweets in an hour below which an hourly burst is ignored
+	scheduleShiftDistance  = 0.5  // total variation distance between hour distributions
+	scheduleShiftMinTweets = 20   // tweets in the window below which the schedule isn't compared
+	replyRatioZScore       = 3.0  // z-score of a change in the share of replies
+	replyRatioMinTweets    = 10   // tweets in the window below which replies aren't compared
+)
+
+// Alert is an unusual change in how an account tweets, compared to its baseline
+type Alert struct {
+	Kind     string  `json:"kind"`
+	Severity string  `json:"severity"`
+	Account  string  `json:"account"`
+	Date     string  `json:"date,omitempty"`   // the day of the anomaly; empty for the whole window
+	Window   string  `json:"window,omitempty"` // the days a whole-window alert covers, e.g. "2025-01-06 to 2025-01-12"
+	Message  string  `json:"message"`
+	Value    float64 `json:"value"`             // what was observed
+	Baseline float64 `json:"baseline"`          // what was expected
+	ZScore   float64 `json:"z_score,omitempty"` // how unusual it is, where that applies
+}
+
+// activityProfile is how an account tweeted over some days
+type activityProfile struct {
+	dailyCounts  map[string]int // tweets per day
+	hourlyCounts map[string]int // tweets per day and hour, keyed "YYYY-MM-DD HH"
+	hours        [24]int        // tweets per hour of the day
+	replies      int
+	total        int
+	firstDay     time.Time
+}
+
+func newActivityProfile() *activityProfile {
+	return &activityProfile{dailyCounts: make(map[string]int), hourlyCounts: make(map[string]int)}
+}
+
+func (p *activityProfile) add(tweet Tweet, createdAt time.Time) {
+	day := time.Date(createdAt.Year(), createdAt.Month(), createdAt.Day(), 0, 0, 0, 0, createdAt.Location())
+	p.dailyCounts[day.Format(dateLayout)]++
+	p.hourlyCounts[createdAt.Format("2006-01-02 15")]++
+	p.hours[createdAt.Hour()]++
+	p.total++
+	if tweet.InReplyToID != "" || tweet.InReplyToUsername != "" {
+		p.replies++
+	}
+	if p.firstDay.IsZero() || day.Before(p.firstDay) {
+		p.firstDay = day
+	}
+}
+
+// baselineStats are the mean and standard deviation of the daily counts of a baseline
+type baselineStats struct {
+	days       int
+	mean       float64
+	stddev     float64
+	maxHourly  int
+	replyShare float64
+}
+
+// stats summarizes the daily counts from the account's first tweet in the baseline to end,
+// counting the days without tweets as zeros
+func (p *activityProfile) stats(end time.Time) baselineStats {
+	var counts []float64
+	for day := p.firstDay; day.Before(end); day = day.AddDate(0, 0, 1) {
+		counts = append(counts, float64(p.dailyCounts[day.Format(dateLayout)]))
+	}
+	stats := baselineStats{days: len(counts)}
+	if len(counts) == 0 {
+		return stats
+	}
+	for _, count := range counts {
+		stats.mean += count
+	}
+	stats.mean /= float64(len(counts))
+	for _, count := range counts {
+		stats.stddev += (count - stats.mean) * (count - stats.mean)
+	}
+	stats.stddev = math.Sqrt(stats.stddev / float64(len(counts)))
+	for _, count := range p.hourlyCounts {
+		stats.maxHourly = max(stats.maxHourly, count)
+	}
+	if p.total > 0 {
+		stats.replyShare = float64(p.replies) / float64(p.total)
+	}
+	return stats
+}
+
+// detectAnomalies compares how the accounts of the list tweeted on each of days days from
+// startDate to the baselineDays before it, and returns the alerts, most severe first
+func (a *App) detectAnomalies(ctx context.Context, accountsList string, startDate time.Time, days int) ([]Alert, error) {
+	if a.baselineDays <= 0 {
+		return nil, nil
+	}
+	accounts, err := a.selectAccounts(accountsList, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("didn't get accounts: %v", err)
+	}
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+
+	location := startDate.Location()
+	windowStart := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, location)
+	windowEnd := windowStart.AddDate(0, 0, days)
+	span := windowStart.Format(dateLayout)
+	if days > 1 {
+		span += " to " + windowEnd.AddDate(0, 0, -1).Format(dateLayout)
+	}
+	baselineStart := windowStart.AddDate(0, 0, -a.baselineDays)
+
+	baselines := make(map[string]*activityProfile)
+	windows := make(map[string]*activityProfile)
+	for _, account := range accounts {
+		baselines[account.Handle] = newActivityProfile()
+		windows[account.Handle] = newActivityProfile()
+	}
+	err = a.store.EachTweet(ctx, TweetQuery{Accounts: accountHandles(accounts), Since: baselineStart, Until: windowEnd}, func(tweet Tweet) error {
+		createdAt, err := parseTweetTime(tweet.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("tweet %s: %v", tweet.ID, err)
+		}
+		createdAt = createdAt.In(location)
+		profiles := windows
+		if createdAt.Before(windowStart) {
+			profiles = baselines
+		}
+		if profile, ok := profiles[tweet.Username]; ok {
+			profile.add(tweet, createdAt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tweet history: %v", err)
+	}
+
+	var alerts []Alert
+	for _, account := range accounts {
+		baseline := baselines[account.Handle]
+		if baseline.total == 0 {
+			continue
+		}
+		stats := baseline.stats(windowStart)
+		if stats.days < minBaselineDays {
+			continue
+		}
+		window := windows[account.Handle]
+		for i := 0; i < days; i++ {
+			day := windowStart.AddDate(0, 0, i)
+			if !account.ActiveOn(day) {
+				continue
+			}
+			alerts = append(alerts, dailyAlerts(account.Handle, day, window, stats)...)
+		}
+		alerts = append(alerts, windowAlerts(account.Handle, span, window, baseline, stats)...)
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		if severityRank[alerts[i].Severity] != severityRank[alerts[j].Severity] {
+			return severityRank[alerts[i].Severity] > severityRank[alerts[j].Severity]
+		}
+		if alerts[i].Date != alerts[j].Date {
+			return alerts[i].Date < alerts[j].Date
+		}
+		return alerts[i].Account < alerts[j].Account
+	})
+	return alerts, nil
+}
+
+// dailyAlerts checks one day of an account against its baseline
+func dailyAlerts(account string, day time.Time, window *activityProfile, stats baselineStats) []Alert {
+	date := day.Format(dateLayout)
+	count := float64(window.dailyCounts[date])
+	var alerts []Alert
+
+	if count == 0 && stats.mean >= silentMinMean {
+		severity := severityWarning
+		if stats.mean >= silentCriticalMean {
+			severity = severityCritical
+		}
+		alerts = append(alerts, Alert{
+			Kind:     alertSilent,
+			Severity: severity,
+			Account:  account,
+			Date:     date,
+			Message:  fmt.Sprintf("@%s went silent on %s, after %.1f tweets a day", account, date, stats.mean),
+			Baseline: stats.mean,
+		})
+	} else if z := (count - stats.mean) / math.Max(stats.stddev, math.Max(math.Sqrt(stats.mean), 1)); math.Abs(z) >= burstZScore {
+		kind, change := alertBurst, "more"
+		if z < 0 {
+			kind, change = alertDrop, "fewer"
+		}
+		severity := severityWarning
+		if math.Abs(z) >= criticalZScore {
+			severity = severityCritical
+		}
+		alerts = append(alerts, Alert{
+			Kind:     kind,
+			Severity: severity,
+			Account:  account,
+			Date:     date,
+			Message:  fmt.Sprintf("@%s tweeted %d times on %s, %s than its usual %.1f", account, int(count), date, change, stats.mean),
+			Value:    count,
+			Baseline: stats.mean,
+			ZScore:   math.Round(z*100) / 100,
+		})
+	}
+
+	for hour := 0; hour < 24; hour++ {
+		key := fmt.Sprintf("%s %02d", date, hour)
+		if count := window.hourlyCounts[key]; count >= hourlyBurstMin && count > 2*stats.maxHourly {
+			alerts = append(alerts, Alert{
+				Kind:     alertHourlyBurst,
+				Severity: severityWarning,
+				Account:  account,
+				Date:     date,
+				Message:  fmt.Sprintf("@%s tweeted %d times between %02d:00 and %02d:00 on %s, against at most %d an hour before", account, count, hour, hour+1, date, stats.maxHourly),
+				Value:    float64(count),
+				Baseline: float64(stats.maxHourly),
+			})
+		}
+	}
+	return alerts
+}
+
+// windowAlerts checks the hours and replies of an account over the whole window, the days span
+func windowAlerts(account string, span string, window *activityProfile, baseline *activityProfile, stats baselineStats) []Alert {
+	var alerts []Alert
+
+	if window.total >= scheduleShiftMinTweets {
+		if distance := hoursDistance(window.hours, baseline.hours); distance >= scheduleShiftDistance {
+			alerts = append(alerts, Alert{
+				Kind:     alertScheduleShift,
+				Severity: severityInfo,
+				Account:  account,
+				Window:   span,
+				Message: fmt.Sprintf("@%s changed its schedule: its busiest hour is %02d:00, against %02d:00 before",
+					account, busiestHour(window.hours), busiestHour(baseline.hours)),
+				Value:    math.Round(distance*100) / 100,
+				Baseline: scheduleShiftDistance,
+			})
+		}
+	}
+
+	if window.total >= replyRatioMinTweets {
+		share := float64(window.replies) / float64(window.total)
+		// Clamped, so that an account that never replied doesn't alert on its first reply
+		expected := math.Min(math.Max(stats.replyShare, 0.02), 0.98)
+		z := (share - expected) / math.Sqrt(expected*(1-expected)/float64(window.total))
+		if math.Abs(z) >= replyRatioZScore {
+			alerts = append(alerts, Alert{
+				Kind:     alertReplyRatio,
+				Severity: severityInfo,
+				Account:  account,
+				Window:   span,
+				Message: fmt.Sprintf("@%s's replies went from %.0f%% to %.0f%% of its tweets",
+					account, 100*stats.replyShare, 100*share),
+				Value:    math.Round(share*100) / 100,
+				Baseline: math.Round(stats.replyShare*100) / 100,
+				ZScore:   math.Round(z*100) / 100,
+			})
+		}
+	}
+	return alerts
+}
+
+// hoursDistance is the total variation distance between two distributions of tweets over
+// the hours of the day: 0 when they're the same, 1 when they don't overlap
+func hoursDistance(a [24]int, b [24]int) float64 {
+	totalA, totalB := 0, 0
+	for hour := 0; hour < 24; hour++ {
+		totalA += a[hour]
+		totalB += b[hour]
+	}
+	if totalA == 0 || totalB == 0 {
+		return 0
+	}
+	distance := 0.0
+	for hour := 0; hour < 24; hour++ {
+		distance += math.Abs(float64(a[hour])/float64(totalA) - float64(b[hour])/float64(totalB))
+	}
+	return distance / 2
+}
+
+func busiestHour(hours [24]int) int {
+	busiest := 0
+	for hour := range hours {
+		if hours[hour] > hours[busiest] {
+			busiest = hour
+		}
+	}
+	return busiest
+}
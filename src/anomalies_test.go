@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// anomalyWindow is the first day of the window the anomaly tests check
+var anomalyWindow = time.Date(2025, 2, 10, 0, 0, 0, 0, time.UTC)
+
+// postsAt is days days of tweets of account from from, each day one tweet at each of hours.
+// With replies, the tweets are replies.
+func postsAt(account string, from time.Time, days int, hours []int, replies bool) []Tweet {
+	var tweets []Tweet
+	for day := 0; day < days; day++ {
+		date := from.AddDate(0, 0, day)
+		for i, hour := range hours {
+			tweet := Tweet{
+				ID:        fmt.Sprintf("%s-%s-%d", account, date.Format(dateLayout), i),
+				Username:  account,
+				CreatedAt: date.Add(time.Duration(hour)*time.Hour + time.Duration(i)*time.Minute).Format("2006-01-02 15:04:05"),
+				Text:      "gm",
+			}
+			if replies {
+				tweet.InReplyToUsername = "bob"
+			}
+			tweets = append(tweets, tweet)
+		}
+	}
+	return tweets
+}
+
+// hoursFrom is n hours in a row from first, wrapping around midnight
+func hoursFrom(first int, n int) []int {
+	var hours []int
+	for i := 0; i < n; i++ {
+		hours = append(hours, (first+i)%24)
+	}
+	return hours
+}
+
+// sameHour is n tweets in the hour
+func sameHour(hour int, n int) []int {
+	return slices.Repeat([]int{hour}, n)
+}
+
+// baseline is the 28 days of tweets before the window
+func baseline(account string, hours []int) []Tweet {
+	return postsAt(account, anomalyWindow.AddDate(0, 0, -28), 28, hours, false)
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	// noisy alternates between 2 and 8 tweets a day: a mean of 5 and a standard deviation of 3
+	var noisy []Tweet
+	for day := 0; day < 28; day++ {
+		n := 2
+		if day%2 == 1 {
+			n = 8
+		}
+		noisy = append(noisy, postsAt("alice", anomalyWindow.AddDate(0, 0, day-28), 1, hoursFrom(8, n), false)...)
+	}
+
+	tests := []struct {
+		name   string
+		days   int
+		tweets [][]Tweet
+		want   []string // kind, severity, account and day or window of each alert, in order
+	}{
+		{
+			name:   "steady account",
+			days:   1,
+			tweets: [][]Tweet{baseline("alice", hoursFrom(9, 5)), postsAt("alice", anomalyWindow, 1, hoursFrom(9, 5), false)},
+		},
+		{
+			// The standard deviation of a baseline without variance is taken as that of a
+			// Poisson count, sqrt(5), so 9 tweets isn't a burst
+			name:   "zero-variance baseline, a few more tweets",
+			days:   1,
+			tweets: [][]Tweet{baseline("alice", hoursFrom(9, 5)), postsAt("alice", anomalyWindow, 1, hoursFrom(9, 9), false)},
+		},
+		{
+			name:   "zero-variance baseline, burst",
+			days:   1,
+			tweets: [][]Tweet{baseline("alice", hoursFrom(9, 5)), postsAt("alice", anomalyWindow, 1, hoursFrom(9, 12), false)},
+			want:   []string{"burst warning @alice 2025-02-10"},
+		},
+		{
+			// 19 tweets, so that there are too few to compare the schedule
+			name:   "critical burst",
+			days:   1,
+			tweets: [][]Tweet{baseline("alice", hoursFrom(9, 5)), postsAt("alice", anomalyWindow, 1, hoursFrom(0, 19), false)},
+			want:   []string{"burst critical @alice 2025-02-10"},
+		},
+		{
+			// 12 tweets are only 2.3 standard deviations above a noisy baseline
+			name:   "noisy baseline",
+			days:   1,
+			tweets: [][]Tweet{noisy, postsAt("alice", anomalyWindow, 1, hoursFrom(9, 12), false)},
+		},
+		{
+			name:   "drop",
+			days:   1,
+			tweets: [][]Tweet{baseline("alice", hoursFrom(0, 20)), postsAt("alice", anomalyWindow, 1, hoursFrom(9, 2), false)},
+			want:   []string{"drop warning @alice 2025-02-10"},
+		},
+		{
+			name:   "silent",
+			days:   1,
+			tweets: [][]Tweet{baseline("alice", hoursFrom(9, 5))},
+			want:   []string{"silent warning @alice 2025-02-10"},
+		},
+		{
+			name:   "silent after many tweets a day",
+			days:   1,
+			tweets: [][]Tweet{baseline("alice", hoursFrom(9, 12))},
+			want:   []string{"silent critical @alice 2025-02-10"},
+		},
+		{
+			name:   "silent account that rarely tweets",
+			days:   1,
+			tweets: [][]Tweet{baseline("alice", hoursFrom(9, 2))},
+		},
+		{
+			name:   "hourly burst",
+			days:   1,
+			tweets: [][]Tweet{baseline("alice", hoursFrom(9, 5)), postsAt("alice", anomalyWindow, 1, sameHour(14, 12), false)},
+			want:   []string{"burst warning @alice 2025-02-10", "hourly_burst warning @alice 2025-02-10"},
+		},
+		{
+			name:   "schedule shift",
+			days:   7,
+			tweets: [][]Tweet{baseline("alice", hoursFrom(9, 5)), postsAt("alice", anomalyWindow, 7, hoursFrom(20, 5), false)},
+			want:   []string{"schedule_shift info @alice 2025-02-10 to 2025-02-16"},
+		},
+		{
+			name:   "reply ratio",
+			days:   1,
+			tweets: [][]Tweet{baseline("alice", hoursFrom(9, 12)), postsAt("alice", anomalyWindow, 1, hoursFrom(9, 12), true)},
+			want:   []string{"reply_ratio info @alice 2025-02-10"},
+		},
+		{
+			name:   "no history",
+			days:   1,
+			tweets: [][]Tweet{postsAt("bob", anomalyWindow, 1, sameHour(14, 30), true)},
+		},
+		{
+			name:   "less than a week of history",
+			days:   1,
+			tweets: [][]Tweet{postsAt("alice", anomalyWindow.AddDate(0, 0, -5), 5, hoursFrom(9, 5), false)},
+		},
+		{
+			name: "several accounts, most severe first",
+			days: 1,
+			tweets: [][]Tweet{
+				baseline("alice", hoursFrom(9, 5)), postsAt("alice", anomalyWindow, 1, hoursFrom(9, 12), false),
+				baseline("bob", hoursFrom(9, 12)),
+			},
+			want: []string{"silent critical @bob 2025-02-10", "burst warning @alice 2025-02-10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t, nil, slices.Concat(tt.tweets...))
+			app.baselineDays = 28
+
+			alerts, err := app.detectAnomalies(context.Background(), "test", anomalyWindow, tt.days)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, alert := range alerts {
+				got = append(got, fmt.Sprintf("%s %s @%s %s", alert.Kind, alert.Severity, alert.Account, alert.Date+alert.Window))
+				if alert.Message == "" {
+					t.Errorf("%s alert has no message", alert.Kind)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("alerts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHoursDistance(t *testing.T) {
+	var morning, evening, both [24]int
+	morning[9], evening[21] = 4, 4
+	both[9], both[21] = 2, 2
+	tests := []struct {
+		name string
+		a, b [24]int
+		want float64
+	}{
+		{"same hours", morning, morning, 0},
+		{"other hours", morning, evening, 1},
+		{"half the tweets moved", morning, both, 0.5},
+		{"no tweets", morning, [24]int{}, 0},
+	}
+	for _, tt := range tests {
+		if got := hoursDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: distance = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config selects the backends an App runs on. Empty fields are filled from the environment.
+type Config struct {
+	Model     string        // LLM model used for summaries
+	OutputDir string        // directory where reports are written
+	Store     string        // tweet store: "postgres" (default) or "file"
+	StoreDir  string        // directory of the file store
+	Provider  string        // LLM provider: "openai" (default), "openai-compatible" or "fake"
+	NoLLM     bool          // open no LLM provider, for commands that never call one
+	BaseURL   string        // base URL of the openai-compatible provider
+	Workers   int           // days and accounts processed in parallel, and LLM requests in flight
+	LLMRPM    int           // maximum LLM requests started per minute; 0 means no limit
+	CacheDir  string        // directory of the LLM response cache
+	CacheTTL  time.Duration // how long cached LLM responses are reused; 0 means forever
+	NoCache   bool          // don't reuse cached LLM responses
+	// LLMRetries is how many times a failed LLM request is tried in total, and
+	// LLMErrorBudget how many requests may fail for good before the run stops (0: no limit)
+	LLMRetries       int
+	LLMErrorBudget   int
+	PricesFile       string        // JSON prices per model, overriding the built-in ones
+	MaxCost          float64       // USD the LLM calls of a run may cost before it stops; 0 means no cap
+	ReportDir        string        // directory of stored daily reports, when tweets aren't in postgres
+	Refresh          bool          // regenerate daily reports even when they are stored
+	Timezone         string        // IANA zone in which report days start and end, e.g. "Europe/Berlin"
+	Formats          []string      // formats multi-day reports are saved in: json, md and html (default: all three)
+	Verify           string        // how the claims of summaries are verified: off, lexical (default) or llm
+	Injection        string        // what to do with tweets that look like prompt injection: off, flag or quarantine (default)
+	AccountFilter    AccountFilter // which accounts of the list reports cover, by their registry metadata
+	GroupBy          string        // account field multi-day reports group tweet counts by, if any
+	BaselineDays     int           // days of history multi-day reports compare posting cadence to; 0 disables alerts
+	AlertMinSeverity string        // least severe alerts that are sent: info, warning (default) or critical
+	QuietHours       string        // span of the day in which only critical alerts are sent, e.g. "22:00-07:00"
+	AlertLog         string        // local log alerts are appended to; empty for none
+	AlertStateFile   string        // where sent and held alerts are recorded
+	ClassifierFile   string        // trained account classifier; the built-in weights are used if it doesn't exist
+}
+
+// loadEnv reads .env if there is one; offline setups may not have it
+func loadEnv() error {
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("error loading .env file: %v", err)
+	}
+	return nil
+}
+
+// withDefaults fills empty fields from TWEET_STORE, TWEET_STORE_DIR, LLM_PROVIDER, LLM_BASE_URL,
+// LLM_REQUESTS_PER_MINUTE, LLM_CACHE_DIR, LLM_PRICES_FILE, REPORT_STORE_DIR, REPORT_TIMEZONE,
+// ALERT_MIN_SEVERITY, ALERT_QUIET_HOURS, ALERT_LOG and CLASSIFIER_FILE
+func (c Config) withDefaults() Config {
+	if c.Store == "" {
+		c.Store = os.Getenv("TWEET_STORE")
+	}
+	if c.Store == "" {
+		c.Store = "postgres"
+	}
+	if c.StoreDir == "" {
+		c.StoreDir = os.Getenv("TWEET_STORE_DIR")
+	}
+	if c.StoreDir == "" {
+		c.StoreDir = "./data/tweets"
+	}
+	if c.Provider == "" {
+		c.Provider = os.Getenv("LLM_PROVIDER")
+	}
+	if c.Provider == "" {
+		c.Provider = "openai"
+	}
+	if c.BaseURL == "" {
+		c.BaseURL = os.Getenv("LLM_BASE_URL")
+	}
+	if c.Workers < 1 {
+		c.Workers = 1
+	}
+	if c.LLMRPM == 0 {
+		c.LLMRPM, _ = strconv.Atoi(os.Getenv("LLM_REQUESTS_PER_MINUTE"))
+	}
+	if c.CacheDir == "" {
+		c.CacheDir = os.Getenv("LLM_CACHE_DIR")
+	}
+	if c.CacheDir == "" {
+		c.CacheDir = "./data/cache/llm"
+	}
+	if c.PricesFile == "" {
+		c.PricesFile = os.Getenv("LLM_PRICES_FILE")
+	}
+	if c.ReportDir == "" {
+		c.ReportDir = os.Getenv("REPORT_STORE_DIR")
+	}
+	if c.ReportDir == "" {
+		c.ReportDir = "./data/reports/daily"
+	}
+	if c.Timezone == "" {
+		c.Timezone = os.Getenv("REPORT_TIMEZONE")
+	}
+	// UTC rather than the host's zone, so that every machine cuts days the same way
+	if c.Timezone == "" {
+		c.Timezone = "UTC"
+	}
+	if len(c.Formats) == 0 {
+		c.Formats = append([]string(nil), reportFormats...)
+	}
+	if c.Verify == "" {
+		c.Verify = verifyLexical
+	}
+	if c.Injection == "" {
+		c.Injection = injectionQuarantine
+	}
+	if c.AlertMinSeverity == "" {
+		c.AlertMinSeverity = os.Getenv("ALERT_MIN_SEVERITY")
+	}
+	if c.AlertMinSeverity == "" {
+		c.AlertMinSeverity = severityWarning
+	}
+	if c.QuietHours == "" {
+		c.QuietHours = os.Getenv("ALERT_QUIET_HOURS")
+	}
+	if c.AlertLog == "" {
+		c.AlertLog = os.Getenv("ALERT_LOG")
+	}
+	if c.AlertLog == "" {
+		c.AlertLog = "./data/alerts/alerts.jsonl"
+	}
+	if c.AlertStateFile == "" {
+		c.AlertStateFile = "./data/state/alerts.json"
+	}
+	if c.ClassifierFile == "" {
+		c.ClassifierFile = os.Getenv("CLASSIFIER_FILE")
+	}
+	if c.ClassifierFile == "" {
+		c.ClassifierFile = "./data/classifier.json"
+	}
+	return c
+}
+
+// newLLMProvider creates the LLM provider selected by the config. It returns nil
+// for openai without OPENAI_API_KEY, and reports then fall back to simple summaries.
+func newLLMProvider(cfg Config) (LLMProvider, error) {
+	switch cfg.Provider {
+	case "openai":
+		token := os.Getenv("OPENAI_API_KEY")
+		if token == "" {
+			return nil, nil
+		}
+		return newOpenAIProvider(token), nil
+	case "openai-compatible":
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("the openai-compatible provider needs LLM_BASE_URL")
+		}
+		// Local servers usually ignore the key, but some want one to be sent
+		token := os.Getenv("LLM_API_KEY")
+		if token == "" {
+			token = os.Getenv("OPENAI_API_KEY")
+		}
+		return newOpenAICompatibleProvider(cfg.BaseURL, token), nil
+	case "fake":
+		return newFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider %q (want openai, openai-compatible or fake)", cfg.Provider)
+	}
+}
+
+// openTweetStore opens the tweet store selected by the config
+func openTweetStore(ctx context.Context, cfg Config) (TweetStore, error) {
+	switch cfg.Store {
+	case "postgres":
+		return newPostgresStore(ctx, os.Getenv("DATABASE_POOL_URL"))
+	case "file":
+		return newFileStore(cfg.StoreDir)
+	default:
+		return nil, fmt.Errorf("unknown tweet store %q (want postgres or file)", cfg.Store)
+	}
+}
+
+// NewApp loads .env, and opens the tweet store and the LLM provider. Callers must Close the App.
+func NewApp(ctx context.Context, cfg Config) (*App, error) {
+	if err := loadEnv(); err != nil {
+		return nil, err
+	}
+	cfg = cfg.withDefaults()
+
+	switch cfg.Verify {
+	case verifyOff, verifyLexical, verifyLLM:
+	default:
+		return nil, fmt.Errorf("unknown verification mode %q (want off, lexical or llm)", cfg.Verify)
+	}
+	switch cfg.Injection {
+	case injectionOff, injectionFlag, injectionQuarantine:
+	default:
+		return nil, fmt.Errorf("unknown prompt injection mode %q (want off, flag or quarantine)", cfg.Injection)
+	}
+	if cfg.GroupBy != "" && !slices.Contains(accountGroupKeys, cfg.GroupBy) {
+		return nil, fmt.Errorf("unknown account grouping %q (want %s)", cfg.GroupBy, strings.Join(accountGroupKeys, ", "))
+	}
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %v", cfg.Timezone, err)
+	}
+
+	notifier, err := newNotifier(cfg, location)
+	if err != nil {
+		return nil, err
+	}
+	classifier, err := loadClassifierModel(cfg.ClassifierFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var llm LLMProvider
+	if !cfg.NoLLM {
+		if llm, err = newLLMProvider(cfg); err != nil {
+			return nil, err
+		}
+		// Commands that don't summarize, like fetch, don't set a model
+		if llm == nil && cfg.Model != "" {
+			fmt.Println("Warning: OPENAI_API_KEY is not set, so summaries will only count tweets")
+		}
+	}
+	var usage *usageTracker
+	if llm != nil {
+		llm = newThrottledProvider(llm, cfg.Workers, cfg.LLMRPM)
+		llm = newRetryingProvider(llm, cfg.LLMRetries, cfg.LLMErrorBudget)
+		// The fake provider is deterministic already, so it isn't worth caching
+		if cfg.Provider != "fake" {
+			if llm, err = newCachingProvider(llm, cfg.BaseURL, cfg.CacheDir, cfg.CacheTTL, cfg.NoCache); err != nil {
+				return nil, err
+			}
+		}
+
+		prices, err := loadModelPrices(cfg.PricesFile)
+		if err != nil {
+			return nil, err
+		}
+		usage = newUsageTracker(llm, prices, cfg.MaxCost)
+		llm = usage
+	}
+
+	store, err := openTweetStore(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	reports, err := openReportStore(store, cfg.ReportDir)
+	if err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	return &App{
+		store:         store,
+		llm:           llm,
+		usage:         usage,
+		reports:       reports,
+		refresh:       cfg.Refresh,
+		location:      location,
+		formats:       cfg.Formats,
+		verify:        cfg.Verify,
+		injection:     cfg.Injection,
+		accountFilter: cfg.AccountFilter,
+		groupBy:       cfg.GroupBy,
+		baselineDays:  cfg.BaselineDays,
+		notifier:      notifier,
+		classifier:    classifier,
+		model:         cfg.Model,
+		outputDir:     cfg.OutputDir,
+		workers:       cfg.Workers,
+	}, nil
+}
+
+func (a *App) Close() {
+	if a.store != nil {
+		a.store.Close()
+	}
+}
+
+// resetUsage starts counting LLM usage for a new run
+func (a *App) resetUsage() {
+	if a.usage != nil {
+		a.usage.reset()
+	}
+}
+
+// costReport is the LLM usage since the last resetUsage, or nil without an LLM
+func (a *App) costReport() *CostReport {
+	if a.usage == nil {
+		return nil
+	}
+	return a.usage.costReport()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewAppWithoutLLM(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name    string
+		noLLM   bool
+		wantLLM bool
+	}{
+		{"summarizing command", false, true},
+		{"command that never calls an LLM", true, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			app, err := NewApp(context.Background(), Config{
+				Model:          GPT4_o_mini,
+				Store:          "file",
+				StoreDir:       filepath.Join(dir, "tweets"),
+				Provider:       "fake",
+				NoLLM:          tt.noLLM,
+				ReportDir:      filepath.Join(dir, "reports"),
+				AlertLog:       filepath.Join(dir, "alerts.jsonl"),
+				AlertStateFile: filepath.Join(dir, "alerts.json"),
+				ClassifierFile: filepath.Join(dir, "classifier.json"),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer app.Close()
+			if gotLLM := app.llm != nil; gotLLM != tt.wantLLM {
+				t.Errorf("has an LLM = %v, want %v", gotLLM, tt.wantLLM)
+			}
+			if gotUsage := app.usage != nil; gotUsage != tt.wantLLM {
+				t.Errorf("tracks LLM usage = %v, want %v", gotUsage, tt.wantLLM)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// modelContextWindows are the context windows, in tokens, of the models we use.
+// Unknown models, e.g. local ones, get defaultContextWindow.
+var modelContextWindows = map[string]int{
+	GPT3_5_turbo:             16385,
+	GPT4_o:                   128000,
+	GPT4_turbo:               128000,
+	GPT4_o_mini:              128000,
+	"gpt-4o":                 128000,
+	"gpt-4.1":                1047576,
+	"gpt-4.1-mini":           1047576,
+	"gpt-4.1-nano":           1047576,
+	"gpt-4o-mini-2024-07-18": 128000,
+}
+
+const defaultContextWindow = 8192
+
+// summarizePromptTokens is roughly the size of the instructions Summarize wraps around its input
+const summarizePromptTokens = 300
+
+// estimateTokens is a conservative token count: English averages about 4 bytes per
+// token, and emoji and non-latin scripts need fewer, so 3 bytes per token overestimates
+func estimateTokens(text string) int {
+	return (len(text) + 2) / 3
+}
+
+// inputTokenBudget is how many tokens of data a single summarization prompt may contain
+// for model. Half of the window is left for the instructions and the answer.
+func inputTokenBudget(model string) int {
+	window, ok := modelContextWindows[model]
+	if !ok {
+		window = defaultContextWindow
+	}
+	return window/2 - summarizePromptTokens
+}
+
+// chunkLines splits lines into consecutive chunks of at most budget tokens each.
+// A line that is longer than the budget by itself is truncated.
+func chunkLines(lines []string, budget int) [][]string {
+	var chunks [][]string
+	var current []string
+	currentTokens := 0
+	for _, line := range lines {
+		tokens := estimateTokens(line) + 1
+		if tokens > budget {
+			line = truncateToTokens(line, budget-1)
+			tokens = budget
+		}
+		if currentTokens+tokens > budget && len(current) > 0 {
+			chunks = append(chunks, current)
+			current, currentTokens = nil, 0
+		}
+		current = append(current, line)
+		currentTokens += tokens
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// truncateToTokens shortens text to about tokens tokens, without splitting a UTF-8 character
+func truncateToTokens(text string, tokens int) string {
+	limit := tokens * 3
+	if limit < 0 {
+		limit = 0
+	}
+	if len(text) <= limit {
+		return text
+	}
+	cut := strings.ToValidUTF8(text[:limit], "")
+	return strings.TrimRight(cut, "�") + "..."
+}
+
+// summarizeMapReduce summarizes header followed by lines. If that doesn't fit in the model's
+// context, the lines are split into chunks that do, each chunk is summarized, and then the
+// chunk summaries are summarized in turn, until everything fits in a single prompt. The
+// claims of chunk summaries are passed on with their tweet IDs, so the final claims can cite them.
+func summarizeMapReduce(ctx context.Context, llm LLMProvider, model string, header string, lines []string) (string, []ClaimBox, error) {
+	return summarizeLevel(ctx, llm, model, header, lines, 0)
+}
+
+// maxReduceLevels bounds the recursion of summarizeLevel, in case summaries don't get shorter
+const maxReduceLevels = 4
+
+func summarizeLevel(ctx context.Context, llm LLMProvider, model string, header string, lines []string, level int) (string, []ClaimBox, error) {
+	budget := inputTokenBudget(model) - estimateTokens(header)
+	if budget < 100 {
+		return "", nil, fmt.Errorf("model %s has too small a context window to summarize", model)
+	}
+
+	total := 0
+	for _, line := range lines {
+		total += estimateTokens(line) + 1
+	}
+	if total <= budget {
+		return Summarize(ctx, llm, model, header+"\n\n"+strings.Join(lines, "\n"))
+	}
+
+	if level >= maxReduceLevels {
+		return "", nil, fmt.Errorf("input still doesn't fit in %s after %d rounds of summarization", model, level)
+	}
+
+	chunks := chunkLines(lines, budget)
+	fmt.Printf("Input is about %d tokens, over the %d token budget of %s: summarizing in %d parts...\n", total, budget, model, len(chunks))
+
+	var partials []string
+	for i, chunk := range chunks {
+		chunkHeader := fmt.Sprintf("%s\n(part %d of %d)", header, i+1, len(chunks))
+		summary, claims, err := Summarize(ctx, llm, model, chunkHeader+"\n\n"+strings.Join(chunk, "\n"))
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to summarize part %d of %d: %w", i+1, len(chunks), err)
+		}
+		partial := fmt.Sprintf("Summary of part %d of %d: %s", i+1, len(chunks), quoteData(summary))
+		if len(claims) > 0 {
+			partial += fmt.Sprintf("\nClaims of part %d, each after the tweet_ids that support it:", i+1)
+			for _, claim := range claims {
+				partial += "\n" + formatCitedLine(claim.TweetIDs, claim.Text)
+			}
+		}
+		partials = append(partials, partial)
+	}
+
+	reduceHeader := header + "\nThe activity was too long to read at once. These are summaries of its consecutive parts, in order; combine them into a single summary of the whole."
+	return summarizeLevel(ctx, llm, model, reduceHeader, partials, level+1)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// longDay is a day of alice's tweets too long for a single prompt to a model with the default
+// context window, so that they are summarized in parts
+func longDay() []Tweet {
+	var tweets []Tweet
+	for i := 0; i < 60; i++ {
+		tweets = append(tweets, Tweet{
+			ID:        fmt.Sprintf("%d", 2000+i),
+			Username:  "alice",
+			CreatedAt: fmt.Sprintf("2025-01-06 %02d:%02d:00", 8+i/6, i%6*10),
+			Text:      fmt.Sprintf("Update %d: %s", i, strings.Repeat("the agent posted about its new release again ", 9)),
+		})
+	}
+	return tweets
+}
+
+func TestChunkedSummaryFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantFatal bool
+	}{
+		{"cost budget exceeded", fmt.Errorf("%w: spent $1.0000 of $1.0000", errCostBudgetExceeded), true},
+		{"error budget exhausted", fmt.Errorf("%w: %w", errLLMErrorBudgetExhausted, &LLMError{Kind: LLMErrorServer, Err: errors.New("503")}), true},
+		{"one failed call", &LLMError{Kind: LLMErrorServer, Err: errors.New("503")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The first part is summarized, and the second fails
+			fake := newFakeProvider().withFailure(1, tt.err)
+			app := newTestApp(t, fake, longDay())
+			app.model = "local-model"
+
+			report, err := app.generateWeeklyReport(context.Background(), "test", time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC), 1)
+			if tt.wantFatal {
+				// The run must abort on alice's second part, not degrade her section and carry on
+				if !isRunFatal(err) || !errors.Is(err, tt.err) || !strings.Contains(err.Error(), "part 2 of") {
+					t.Fatalf("generateWeeklyReport error = %v, want the run to abort at part 2 with %v", err, tt.err)
+				}
+				if calls := len(fake.Calls()); calls != 1 {
+					t.Errorf("%d LLM calls were answered, want 1 before the run aborted", calls)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("generateWeeklyReport: %v", err)
+			}
+			alice := findAccountReport(t, report, "2025-01-06", "alice")
+			if !alice.Degraded || !strings.Contains(alice.Error, "failed to summarize part 2 of") {
+				t.Errorf("alice's section: degraded %v, error %q, want it degraded by part 2", alice.Degraded, alice.Error)
+			}
+			if len(report.DegradedSections) != 2 {
+				t.Errorf("degraded sections = %+v, want alice's and the overall summary", report.DegradedSections)
+			}
+		})
+	}
+}
+
+func TestSummarizeMapReduceWrapsErrors(t *testing.T) {
+	var lines []string
+	for _, tweet := range longDay() {
+		lines = append(lines, formatCitedLine([]string{tweet.ID}, tweet.Text))
+	}
+	fake := newFakeProvider().withFailure(2, errCostBudgetExceeded)
+
+	_, _, err := summarizeMapReduce(context.Background(), fake, "local-model", "Twitter activity for @alice:", lines)
+	if !errors.Is(err, errCostBudgetExceeded) {
+		t.Fatalf("summarizeMapReduce error = %v, want it to wrap errCostBudgetExceeded", err)
+	}
+	if !strings.Contains(err.Error(), "part 3 of") {
+		t.Errorf("error %q doesn't say which part failed", err)
+	}
+}
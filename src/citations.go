@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Claim is a claim made by an account summary, with the tweets it cites as sources
+type Claim struct {
+	Text     string   `json:"text"`
+	TweetIDs []string `json:"tweet_ids"`
+	// InvalidTweetIDs are IDs the LLM cited that aren't among the tweets it was given
+	InvalidTweetIDs []string `json:"invalid_tweet_ids,omitempty"`
+	// Set by verifyClaims: Status is "supported" or "unsupported", Support is the share of
+	// the claim's words found in its tweets, and Reason the LLM's, when it verified the claim
+	Status     string  `json:"status,omitempty"`
+	VerifiedBy string  `json:"verified_by,omitempty"`
+	Support    float64 `json:"support,omitempty"`
+	Reason     string  `json:"reason,omitempty"`
+}
+
+// formatCitedLine writes tweet IDs in square brackets followed by text, quoted with
+// quoteData, which is how tweets and the claims citing them are written into prompts
+func formatCitedLine(tweetIDs []string, text string) string {
+	var line strings.Builder
+	for _, id := range tweetIDs {
+		fmt.Fprintf(&line, "[%s] ", id)
+	}
+	line.WriteString(quoteData(text))
+	return line.String()
+}
+
+// validateClaims checks the tweet IDs cited by claims against the tweets that were
+// summarized. IDs that aren't among them are moved to InvalidTweetIDs.
+func validateClaims(claims []ClaimBox, tweets []Tweet) []Claim {
+	known := make(map[string]bool)
+	for _, tweet := range tweets {
+		known[tweet.ID] = true
+	}
+
+	validated := make([]Claim, 0, len(claims))
+	for _, claim := range claims {
+		valid := Claim{Text: claim.Text, TweetIDs: []string{}}
+		seen := make(map[string]bool)
+		for _, id := range claim.TweetIDs {
+			id = strings.Trim(strings.TrimSpace(id), "[]")
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if known[id] {
+				valid.TweetIDs = append(valid.TweetIDs, id)
+			} else {
+				valid.InvalidTweetIDs = append(valid.InvalidTweetIDs, id)
+			}
+		}
+		validated = append(validated, valid)
+	}
+	return validated
+}
@@ -0,0 +1,553 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Features of the account classifier, computed from an account's stored tweets
+const (
+	featureIntervalCV       = "interval_cv"       // coefficient of variation of the time between tweets; scheduled posting is below 1
+	featureActiveHours      = "active_hours"      // hours of the day it tweets in, relative to as many tweets spread around the clock
+	featureLexicalDiversity = "lexical_diversity" // moving-average type-token ratio over windows of 50 words
+	featureTemplateReuse    = "template_reuse"    // share of tweets that open with the same three words as another
+	featureEmDash           = "em_dash"           // share of tweets with an em dash
+	featureLLMPhrases       = "llm_phrases"       // share of tweets with phrases LLMs overuse
+	featureReplyLatency     = "reply_latency"     // log10 of the median minutes it takes to reply
+	featureReplyShare       = "reply_share"       // share of tweets that are replies
+)
+
+var classifierFeatures = []string{
+	featureIntervalCV, featureActiveHours, featureLexicalDiversity, featureTemplateReuse,
+	featureEmDash, featureLLMPhrases, featureReplyLatency, featureReplyShare,
+}
+
+const (
+	minClassifierTweets = 10   // tweets below which an account isn't classified
+	lexicalWindow       = 50   // words in each window of the type-token ratio
+	minReplyLatencies   = 3    // replies with a known parent below which reply latency isn't measured
+	classifierL2        = 1.0  // regularization of training, in standardized units
+	classifierRounds    = 5000 // gradient descent steps of training
+	classifierStep      = 0.1
+)
+
+// llmPhrases are words and phrases LLMs use far more than people do
+var llmPhrases = regexp.MustCompile(`(?i)\b(delv(e|es|ing)|tapestry|testament to|it'?s not just|in the (realm|world) of|navigat(e|ing) the|ever-evolving|let'?s dive|rich tapestry|underscores?|as an ai|fascinating|intricate|embark)\b`)
+
+var (
+	urlPattern    = regexp.MustCompile(`https?://\S+`)
+	numberPattern = regexp.MustCompile(`\d+([.,]\d+)*`)
+	wordPattern   = regexp.MustCompile(`[\p{L}\p{N}'#$]+`)
+)
+
+// twitterEpoch is the time tweet IDs count from: a tweet ID shifted right by 22 bits is the
+// milliseconds since it when the tweet was created
+const twitterEpoch = 1288834974657
+
+// ClassifierModel is a logistic regression over the classifier features. A feature
+// contributes its weight times how far it is from its center, in log-odds; missing
+// features contribute nothing.
+type ClassifierModel struct {
+	Description string                `json:"description"`
+	Bias        float64               `json:"bias"`
+	Weights     map[string]float64    `json:"weights"`
+	Centers     map[string]float64    `json:"centers"`
+	Evaluation  *ClassifierEvaluation `json:"evaluation,omitempty"` // nil for the built-in model
+}
+
+// ClassifierEvaluation is how well a trained model predicted each labeled account when
+// trained on all the others
+type ClassifierEvaluation struct {
+	Agents   int     `json:"agents"`
+	Humans   int     `json:"humans"`
+	Brier    float64 `json:"brier"` // mean squared error of the probabilities; 0.25 is no better than a coin
+	LogLoss  float64 `json:"log_loss"`
+	Accuracy float64 `json:"accuracy"` // at a threshold of 0.5
+}
+
+// defaultClassifierModel has weights set by hand from what agents are known to do, not fitted
+// to labeled accounts, so its scores rank accounts but aren't calibrated probabilities
+func defaultClassifierModel() *ClassifierModel {
+	return &ClassifierModel{
+		Description: "built-in weights, not trained",
+		Weights: map[string]float64{
+			featureIntervalCV:       -1.5,
+			featureActiveHours:      4,
+			featureLexicalDiversity: -3,
+			featureTemplateReuse:    4,
+			featureEmDash:           5,
+			featureLLMPhrases:       6,
+			featureReplyLatency:     -1,
+			featureReplyShare:       1,
+		},
+		Centers: map[string]float64{
+			featureIntervalCV:       1.5,
+			featureActiveHours:      0.6,
+			featureLexicalDiversity: 0.75,
+			featureTemplateReuse:    0.1,
+			featureEmDash:           0.02,
+			featureLLMPhrases:       0.02,
+			featureReplyLatency:     1.5,
+			featureReplyShare:       0.3,
+		},
+	}
+}
+
+// loadClassifierModel reads a trained model from path, or returns the built-in one if
+// there is no file there
+func loadClassifierModel(path string) (*ClassifierModel, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return defaultClassifierModel(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read classifier model: %v", err)
+	}
+	var model ClassifierModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("failed to parse classifier model %s: %v", path, err)
+	}
+	return &model, nil
+}
+
+func saveClassifierModel(path string, model *ClassifierModel) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create classifier directory: %v", err)
+	}
+	data, err := json.MarshalIndent(model, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal classifier model: %v", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write classifier model: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write classifier model: %v", err)
+	}
+	return nil
+}
+
+// FeatureContribution is how much a feature moved an account's score, in log-odds
+type FeatureContribution struct {
+	Feature      string  `json:"feature"`
+	Value        float64 `json:"value"`
+	Contribution float64 `json:"contribution"`
+}
+
+// AccountClassification is how likely the classifier finds it that an account is an agent,
+// and why. Only a trained model's scores are probabilities; the built-in model's only rank
+// accounts, and Calibrated tells which.
+type AccountClassification struct {
+	Account       string                `json:"account"`
+	Tweets        int                   `json:"tweets"`
+	Score         float64               `json:"score"` // from 0 to 1
+	Calibrated    bool                  `json:"calibrated"`
+	Contributions []FeatureContribution `json:"contributions"` // largest first
+}
+
+// calibrated tells whether the model's scores are probabilities: only a trained model, which
+// was evaluated on the accounts it was trained on, is calibrated
+func (m *ClassifierModel) calibrated() bool {
+	return m.Evaluation != nil
+}
+
+// Evidence is the three features that moved the score most
+func (c AccountClassification) Evidence() []FeatureContribution {
+	return c.Contributions[:min(len(c.Contributions), 3)]
+}
+
+// logit is the log-odds the model gives features, and each feature's part of it
+func (m *ClassifierModel) logit(features map[string]float64) (float64, []FeatureContribution) {
+	z := m.Bias
+	var contributions []FeatureContribution
+	for _, name := range classifierFeatures {
+		value, ok := features[name]
+		if !ok {
+			continue
+		}
+		contribution := m.Weights[name] * (value - m.Centers[name])
+		z += contribution
+		contributions = append(contributions, FeatureContribution{
+			Feature:      name,
+			Value:        math.Round(value*1000) / 1000,
+			Contribution: math.Round(contribution*1000) / 1000,
+		})
+	}
+	sort.SliceStable(contributions, func(i, j int) bool {
+		return math.Abs(contributions[i].Contribution) > math.Abs(contributions[j].Contribution)
+	})
+	return z, contributions
+}
+
+// classify scores an account from its tweets, or returns nil if it has too few
+func (m *ClassifierModel) classify(account string, tweets []Tweet) *AccountClassification {
+	features, ok := accountFeatures(tweets)
+	if !ok {
+		return nil
+	}
+	z, contributions := m.logit(features)
+	return &AccountClassification{
+		Account:       account,
+		Tweets:        len(tweets),
+		Score:         math.Round(sigmoid(z)*1000) / 1000,
+		Calibrated:    m.calibrated(),
+		Contributions: contributions,
+	}
+}
+
+func sigmoid(z float64) float64 {
+	return 1 / (1 + math.Exp(-z))
+}
+
+// classifyAccounts classifies each of handles from its tweets that match q, most agent-like
+// first. Usernames are matched case-insensitively, so q may leave out the accounts and read
+// the whole window when stored usernames may differ in case from handles.
+func (a *App) classifyAccounts(ctx context.Context, q TweetQuery, handles []string) ([]AccountClassification, error) {
+	byHandle, err := a.tweetsByHandle(ctx, q, handles)
+	if err != nil {
+		return nil, err
+	}
+	var classifications []AccountClassification
+	for _, handle := range handles {
+		if c := a.classifier.classify(handle, byHandle[strings.ToLower(handle)]); c != nil {
+			classifications = append(classifications, *c)
+		}
+	}
+	sort.SliceStable(classifications, func(i, j int) bool { return classifications[i].Score > classifications[j].Score })
+	return classifications, nil
+}
+
+// tweetsByHandle returns the tweets matching q of each of handles, keyed by lowercase handle
+func (a *App) tweetsByHandle(ctx context.Context, q TweetQuery, handles []string) (map[string][]Tweet, error) {
+	byHandle := make(map[string][]Tweet)
+	for _, handle := range handles {
+		byHandle[strings.ToLower(handle)] = nil
+	}
+	err := a.store.EachTweet(ctx, q, func(tweet Tweet) error {
+		handle := strings.ToLower(tweet.Username)
+		if tweets, ok := byHandle[handle]; ok {
+			byHandle[handle] = append(tweets, tweet)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tweets: %v", err)
+	}
+	return byHandle, nil
+}
+
+// classifyList classifies the accounts of the list from their tweets over days days from startDate
+func (a *App) classifyList(ctx context.Context, accountsList string, startDate time.Time, days int) ([]AccountClassification, error) {
+	accounts, err := a.selectAccounts(accountsList, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("didn't get accounts: %v", err)
+	}
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+	windowStart := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
+	handles := accountHandles(accounts)
+	return a.classifyAccounts(ctx, TweetQuery{Accounts: handles, Since: windowStart, Until: windowStart.AddDate(0, 0, days)}, handles)
+}
+
+// accountFeatures computes the classifier features of an account from its tweets. Features
+// that can't be measured, such as reply latency without replies, are left out. Retweets
+// count for timing but not for style, since their text is someone else's.
+func accountFeatures(tweets []Tweet) (map[string]float64, bool) {
+	if len(tweets) < minClassifierTweets {
+		return nil, false
+	}
+	features := make(map[string]float64)
+
+	var times []time.Time
+	var hours [24]bool
+	var own []string
+	replies := 0
+	var latencies []float64
+	for _, tweet := range tweets {
+		createdAt, err := parseTweetTime(tweet.CreatedAt)
+		if err != nil {
+			continue
+		}
+		times = append(times, createdAt)
+		hours[createdAt.UTC().Hour()] = true
+		if tweet.RetweetedID == "" && tweet.RetweetedUsername == "" && !retweetPrefixPattern.MatchString(tweet.Text) {
+			own = append(own, tweet.Text)
+		}
+		if tweet.InReplyToID != "" || tweet.InReplyToUsername != "" {
+			replies++
+			if parent, ok := tweetIDTime(tweet.InReplyToID); ok {
+				// IDs that aren't snowflakes give times that can't be right
+				if latency := createdAt.Sub(parent); latency >= 0 && latency < 7*24*time.Hour {
+					latencies = append(latencies, latency.Minutes())
+				}
+			}
+		}
+	}
+	if len(times) < minClassifierTweets {
+		return nil, false
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	var intervals []float64
+	for i := 1; i < len(times); i++ {
+		intervals = append(intervals, times[i].Sub(times[i-1]).Seconds())
+	}
+	if mean, stddev := meanStddev(intervals); mean > 0 {
+		features[featureIntervalCV] = stddev / mean
+	}
+
+	activeHours := 0
+	for _, active := range hours {
+		if active {
+			activeHours++
+		}
+	}
+	// Tweets at random hours cover 24(1 - (23/24)^n) hours on average
+	aroundTheClock := 24 * (1 - math.Pow(23.0/24, float64(len(times))))
+	features[featureActiveHours] = math.Min(float64(activeHours)/aroundTheClock, 1)
+	features[featureReplyShare] = float64(replies) / float64(len(times))
+	if len(latencies) >= minReplyLatencies {
+		sort.Float64s(latencies)
+		features[featureReplyLatency] = math.Log10(1 + latencies[len(latencies)/2])
+	}
+
+	if len(own) > 0 {
+		var words []string
+		openings := make(map[string]int)
+		var tweetOpenings []string
+		emDashes, phrases := 0, 0
+		for _, text := range own {
+			tokens := styleTokens(text)
+			words = append(words, tokens...)
+			opening := ""
+			if len(tokens) >= 3 {
+				opening = strings.Join(tokens[:3], " ")
+				openings[opening]++
+			}
+			tweetOpenings = append(tweetOpenings, opening)
+			if strings.ContainsRune(text, '—') {
+				emDashes++
+			}
+			if llmPhrases.MatchString(text) {
+				phrases++
+			}
+		}
+		reused := 0
+		for _, opening := range tweetOpenings {
+			if opening != "" && openings[opening] > 1 {
+				reused++
+			}
+		}
+		features[featureTemplateReuse] = float64(reused) / float64(len(own))
+		features[featureEmDash] = float64(emDashes) / float64(len(own))
+		features[featureLLMPhrases] = float64(phrases) / float64(len(own))
+		if len(words) > 0 {
+			features[featureLexicalDiversity] = movingTypeTokenRatio(words, lexicalWindow)
+		}
+	}
+	return features, true
+}
+
+// styleTokens are the lowercase words of a tweet, without the @handles a reply starts with,
+// URLs, and with numbers as #, so that "price is 3.2" and "price is 4.1" read the same
+func styleTokens(text string) []string {
+	text = leadingMentions.ReplaceAllString(text, "")
+	text = urlPattern.ReplaceAllString(text, " ")
+	text = numberPattern.ReplaceAllString(text, "#")
+	return wordPattern.FindAllString(strings.ToLower(text), -1)
+}
+
+// movingTypeTokenRatio is the mean share of distinct words in each window of window words,
+// which unlike the share over all words doesn't fall as an account tweets more
+func movingTypeTokenRatio(words []string, window int) float64 {
+	if len(words) <= window {
+		distinct := make(map[string]bool)
+		for _, word := range words {
+			distinct[word] = true
+		}
+		return float64(len(distinct)) / float64(len(words))
+	}
+	counts := make(map[string]int)
+	for _, word := range words[:window] {
+		counts[word]++
+	}
+	total := float64(len(counts))
+	for i := window; i < len(words); i++ {
+		if counts[words[i-window]]--; counts[words[i-window]] == 0 {
+			delete(counts, words[i-window])
+		}
+		counts[words[i]]++
+		total += float64(len(counts))
+	}
+	return total / float64(len(words)-window+1) / float64(window)
+}
+
+// tweetIDTime is when a tweet was created, from its snowflake ID
+func tweetIDTime(id string) (time.Time, bool) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli((n >> 22) + twitterEpoch), true
+}
+
+func meanStddev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// classifierSample is the features of an account labeled as an agent or not
+type classifierSample struct {
+	account  string
+	features map[string]float64
+	agent    bool
+}
+
+// trainClassifierOn trains a model on the tweets matching q of accounts known to be agents
+// and accounts known to be people. Accounts with too few tweets are left out.
+func (a *App) trainClassifierOn(ctx context.Context, q TweetQuery, agents []string, humans []string) (*ClassifierModel, error) {
+	byHandle, err := a.tweetsByHandle(ctx, q, append(append([]string(nil), agents...), humans...))
+	if err != nil {
+		return nil, err
+	}
+	var samples []classifierSample
+	for _, group := range []struct {
+		handles []string
+		agent   bool
+	}{{agents, true}, {humans, false}} {
+		for _, handle := range group.handles {
+			features, ok := accountFeatures(byHandle[strings.ToLower(handle)])
+			if !ok {
+				fmt.Printf("Warning: @%s has fewer than %d tweets, left out of training\n", handle, minClassifierTweets)
+				continue
+			}
+			samples = append(samples, classifierSample{account: handle, features: features, agent: group.agent})
+		}
+	}
+	return trainClassifier(samples)
+}
+
+// trainClassifier fits a model to labeled accounts by regularized logistic regression, and
+// evaluates it by leaving out each account in turn
+func trainClassifier(samples []classifierSample) (*ClassifierModel, error) {
+	agents := 0
+	for _, sample := range samples {
+		if sample.agent {
+			agents++
+		}
+	}
+	humans := len(samples) - agents
+	if agents < 2 || humans < 2 {
+		return nil, fmt.Errorf("training needs at least 2 agents and 2 humans with enough tweets, got %d and %d", agents, humans)
+	}
+
+	model := fitClassifier(samples)
+	evaluation := &ClassifierEvaluation{Agents: agents, Humans: humans}
+	for i, sample := range samples {
+		rest := append(append([]classifierSample(nil), samples[:i]...), samples[i+1:]...)
+		z, _ := fitClassifier(rest).logit(sample.features)
+		p := sigmoid(z)
+		label := 0.0
+		if sample.agent {
+			label = 1
+		}
+		evaluation.Brier += (p - label) * (p - label)
+		evaluation.LogLoss -= label*math.Log(math.Max(p, 1e-9)) + (1-label)*math.Log(math.Max(1-p, 1e-9))
+		if (p >= 0.5) == sample.agent {
+			evaluation.Accuracy++
+		}
+	}
+	n := float64(len(samples))
+	evaluation.Brier = math.Round(evaluation.Brier/n*1000) / 1000
+	evaluation.LogLoss = math.Round(evaluation.LogLoss/n*1000) / 1000
+	evaluation.Accuracy = math.Round(evaluation.Accuracy/n*1000) / 1000
+	model.Evaluation = evaluation
+	model.Description = fmt.Sprintf("trained on %d agents and %d humans", agents, humans)
+	return model, nil
+}
+
+// fitClassifier runs gradient descent on the features standardized around their means, and
+// turns the weights back into the features' own units. Missing features are at their mean.
+func fitClassifier(samples []classifierSample) *ClassifierModel {
+	model := &ClassifierModel{Weights: make(map[string]float64), Centers: make(map[string]float64)}
+	scales := make(map[string]float64)
+	for _, name := range classifierFeatures {
+		var values []float64
+		for _, sample := range samples {
+			if value, ok := sample.features[name]; ok {
+				values = append(values, value)
+			}
+		}
+		if len(values) > 0 {
+			model.Centers[name], scales[name] = meanStddev(values)
+		}
+	}
+
+	x := make([][]float64, len(samples))
+	y := make([]float64, len(samples))
+	for i, sample := range samples {
+		x[i] = make([]float64, len(classifierFeatures))
+		for j, name := range classifierFeatures {
+			if value, ok := sample.features[name]; ok && scales[name] > 0 {
+				x[i][j] = (value - model.Centers[name]) / scales[name]
+			}
+		}
+		if sample.agent {
+			y[i] = 1
+		}
+	}
+
+	n := float64(len(samples))
+	weights := make([]float64, len(classifierFeatures))
+	bias := 0.0
+	for round := 0; round < classifierRounds; round++ {
+		gradient := make([]float64, len(weights))
+		biasGradient := 0.0
+		for i := range x {
+			z := bias
+			for j, value := range x[i] {
+				z += weights[j] * value
+			}
+			residual := sigmoid(z) - y[i]
+			biasGradient += residual
+			for j, value := range x[i] {
+				gradient[j] += residual * value
+			}
+		}
+		bias -= classifierStep * biasGradient / n
+		for j := range weights {
+			weights[j] -= classifierStep * (gradient[j] + classifierL2*weights[j]) / n
+		}
+	}
+
+	model.Bias = bias
+	for j, name := range classifierFeatures {
+		if scales[name] > 0 {
+			model.Weights[name] = weights[j] / scales[name]
+		}
+	}
+	return model
+}
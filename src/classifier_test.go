@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSeries is n tweets of an account, the first at 2025-01-06 00:00 UTC and each after the
+// one before by gap(i), with text(i)
+func testSeries(n int, gap func(i int) time.Duration, text func(i int) string) []Tweet {
+	var tweets []Tweet
+	at := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			at = at.Add(gap(i))
+		}
+		tweets = append(tweets, Tweet{
+			ID:        strconv.Itoa(100 + i),
+			Username:  "alice",
+			CreatedAt: at.Format("2006-01-02 15:04:05"),
+			Text:      text(i),
+		})
+	}
+	return tweets
+}
+
+func every(d time.Duration) func(int) time.Duration { return func(int) time.Duration { return d } }
+
+// distinctText is a tweet that shares no opening words with the others. Numbers all read the
+// same to the style features, so tweets differ in letters.
+func distinctText(i int) string {
+	word := strings.Repeat(string(rune('a'+i%26)), 1+i/26)
+	return fmt.Sprintf("%s went on about %s and then some", word, word)
+}
+
+func TestAccountFeatures(t *testing.T) {
+	replies := testSeries(10, every(time.Hour), distinctText)
+	for i := range replies {
+		// Each reply is 10 minutes after its parent, whose time is in its snowflake ID
+		createdAt, _ := parseTweetTime(replies[i].CreatedAt)
+		parent := createdAt.Add(-10*time.Minute).UnixMilli() - twitterEpoch
+		replies[i].InReplyToID = strconv.FormatInt(parent<<22, 10)
+	}
+	retweets := testSeries(10, every(time.Hour), func(i int) string {
+		if i%2 == 0 {
+			return "RT @bob: someone else's words — with a dash"
+		}
+		return distinctText(i)
+	})
+
+	tests := []struct {
+		name        string
+		tweets      []Tweet
+		want        map[string]float64
+		wantMissing []string
+		wantOK      bool
+	}{
+		{
+			name:   "too few tweets",
+			tweets: testSeries(minClassifierTweets-1, every(time.Hour), distinctText),
+		},
+		{
+			name:   "hourly around the clock",
+			tweets: testSeries(24, every(time.Hour), distinctText),
+			// 24 tweets at random hours would cover about 15 hours, so 24 is capped at 1
+			want:        map[string]float64{featureIntervalCV: 0, featureActiveHours: 1, featureTemplateReuse: 0, featureEmDash: 0, featureReplyShare: 0},
+			wantMissing: []string{featureReplyLatency},
+			wantOK:      true,
+		},
+		{
+			name:   "one hour of the day",
+			tweets: testSeries(10, every(time.Minute), distinctText),
+			want:   map[string]float64{featureActiveHours: 1 / (24 * (1 - math.Pow(23.0/24, 10)))},
+			wantOK: true,
+		},
+		{
+			name: "irregular intervals",
+			// Gaps of 1 and 3 hours in turn: a mean of 2 and a standard deviation of 1
+			tweets: testSeries(11, func(i int) time.Duration { return time.Duration(1+2*(i%2)) * time.Hour }, distinctText),
+			want:   map[string]float64{featureIntervalCV: 0.5},
+			wantOK: true,
+		},
+		{
+			name: "template reuse and em dashes",
+			tweets: testSeries(10, every(time.Hour), func(i int) string {
+				switch {
+				case i < 4:
+					return fmt.Sprintf("gm frens today is day %d", i)
+				case i < 7:
+					return distinctText(i) + " — really"
+				default:
+					return distinctText(i)
+				}
+			}),
+			want:   map[string]float64{featureTemplateReuse: 0.4, featureEmDash: 0.3},
+			wantOK: true,
+		},
+		{
+			name:   "retweets only count for timing",
+			tweets: retweets,
+			want:   map[string]float64{featureEmDash: 0, featureIntervalCV: 0},
+			wantOK: true,
+		},
+		{
+			name:   "reply latency",
+			tweets: replies,
+			want:   map[string]float64{featureReplyShare: 1, featureReplyLatency: math.Log10(11)},
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			features, ok := accountFeatures(tt.tweets)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			for name, want := range tt.want {
+				got, present := features[name]
+				if !present || math.Abs(got-want) > 1e-3 {
+					t.Errorf("%s = %v (present: %v), want %v", name, got, present, want)
+				}
+			}
+			for _, name := range tt.wantMissing {
+				if _, present := features[name]; present {
+					t.Errorf("%s = %v, want it left out", name, features[name])
+				}
+			}
+		})
+	}
+}
+
+func TestMovingTypeTokenRatio(t *testing.T) {
+	tests := []struct {
+		words  string
+		window int
+		want   float64
+	}{
+		{"all different words", 50, 1},
+		{"a a b b", 50, 0.5},
+		{"a b a b a b a b", 2, 1},
+		{"a a a a a a", 2, 0.5},
+		// Windows: a b c, b c a, c a a, a a a
+		{"a b c a a a", 3, (1 + 1 + 2.0/3 + 1.0/3) / 4},
+	}
+	for _, tt := range tests {
+		if got := movingTypeTokenRatio(strings.Fields(tt.words), tt.window); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("movingTypeTokenRatio(%q, %d) = %v, want %v", tt.words, tt.window, got, tt.want)
+		}
+	}
+}
+
+// syntheticSamples are agents that post on a schedule with em dashes, and people who don't
+func syntheticSamples(agents int, humans int) []classifierSample {
+	var samples []classifierSample
+	for i := 0; i < agents+humans; i++ {
+		agent := i < agents
+		jitter := float64(i%3) / 20
+		features := map[string]float64{
+			featureIntervalCV:  1.4 + jitter,
+			featureActiveHours: 0.5 + jitter,
+			featureEmDash:      0.02 + jitter/10,
+		}
+		if agent {
+			features = map[string]float64{
+				featureIntervalCV:  0.3 + jitter,
+				featureActiveHours: 0.95 - jitter,
+				featureEmDash:      0.4 + jitter,
+			}
+		}
+		samples = append(samples, classifierSample{account: fmt.Sprintf("account%d", i), features: features, agent: agent})
+	}
+	return samples
+}
+
+func TestTrainClassifier(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []classifierSample
+		wantErr bool
+	}{
+		{"separable accounts", syntheticSamples(6, 6), false},
+		{"too few people", syntheticSamples(6, 1), true},
+		{"too few agents", syntheticSamples(1, 6), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, err := trainClassifier(tt.samples)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("trained a model, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !model.calibrated() || model.Evaluation.Agents != 6 || model.Evaluation.Humans != 6 {
+				t.Fatalf("evaluation = %+v, want one of 6 agents and 6 humans", model.Evaluation)
+			}
+			if model.Evaluation.Accuracy != 1 || model.Evaluation.Brier > 0.1 {
+				t.Errorf("left-out accuracy %.2f and Brier score %.3f, want the accounts told apart", model.Evaluation.Accuracy, model.Evaluation.Brier)
+			}
+			for _, sample := range tt.samples {
+				z, _ := model.logit(sample.features)
+				if (sigmoid(z) > 0.5) != sample.agent {
+					t.Errorf("%s scores %.2f, agent: %v", sample.account, sigmoid(z), sample.agent)
+				}
+			}
+		})
+	}
+}
+
+func TestClassifyCalibration(t *testing.T) {
+	trained, err := trainClassifier(syntheticSamples(6, 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tweets := testSeries(20, every(time.Hour), distinctText)
+
+	tests := []struct {
+		name           string
+		model          *ClassifierModel
+		wantCalibrated bool
+	}{
+		{"built-in model", defaultClassifierModel(), false},
+		{"trained model", trained, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.model.classify("alice", tweets)
+			if c == nil {
+				t.Fatal("alice wasn't classified")
+			}
+			if c.Calibrated != tt.wantCalibrated {
+				t.Errorf("calibrated = %v, want %v", c.Calibrated, tt.wantCalibrated)
+			}
+		})
+	}
+}
@@ -0,0 +1,798 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// reportOptions holds the flags shared by the commands that read tweets over a date window
+type reportOptions struct {
+	accountsList string
+	start        string
+	end          string
+	days         int
+	model        string
+	provider     string
+	baseURL      string
+	outputDir    string
+	workers      int
+	llmRPM       int
+	cacheTTL     time.Duration
+	noCache      bool
+	llmRetries   int
+	errorBudget  int
+	pricesFile   string
+	maxCost      float64
+	noLLM        bool // the command never calls an LLM
+	refresh      bool
+	timezone     string
+	formats      string
+	verify       string
+	injection    string
+	categories   string
+	operators    string
+	tags         string
+	groupBy      string
+	baselineDays int
+	classifier   string
+	storeOptions
+}
+
+// storeOptions holds the flags that select the tweet store
+type storeOptions struct {
+	store    string
+	storeDir string
+}
+
+func addStoreFlags(fs *flag.FlagSet, opts *storeOptions) {
+	fs.StringVar(&opts.store, "store", "", "tweet store, postgres or file (default: $TWEET_STORE, else postgres)")
+	fs.StringVar(&opts.storeDir, "store-dir", "", "directory of the file store (default: $TWEET_STORE_DIR, else ./data/tweets)")
+}
+
+func addReportFlags(fs *flag.FlagSet) *reportOptions {
+	opts := &reportOptions{}
+	fs.StringVar(&opts.accountsList, "accounts", "ai-og", "accounts list to use (a .json registry or .txt file in ./data, without the extension)")
+	fs.StringVar(&opts.start, "start", "", "first day of the window, YYYY-MM-DD")
+	fs.StringVar(&opts.end, "end", "", "last day of the window, YYYY-MM-DD (default: yesterday)")
+	fs.IntVar(&opts.days, "days", 7, "number of days in the window, used unless both -start and -end are given")
+	fs.StringVar(&opts.model, "model", GPT4_o_mini, "LLM model used for summaries")
+	fs.StringVar(&opts.provider, "provider", "", "LLM provider: openai, openai-compatible or fake (default: $LLM_PROVIDER, else openai)")
+	fs.StringVar(&opts.baseURL, "llm-base-url", "", "base URL of the openai-compatible provider (default: $LLM_BASE_URL)")
+	fs.StringVar(&opts.outputDir, "out", "./data/reports", "directory where reports are written")
+	fs.IntVar(&opts.workers, "workers", 4, "days and accounts summarized in parallel")
+	fs.IntVar(&opts.llmRPM, "llm-rpm", 0, "maximum LLM requests per minute (default: $LLM_REQUESTS_PER_MINUTE, else no limit)")
+	fs.DurationVar(&opts.cacheTTL, "cache-ttl", 30*24*time.Hour, "how long cached LLM responses are reused, 0 for forever")
+	fs.IntVar(&opts.llmRetries, "llm-retries", 5, "attempts per LLM request on rate limits, server and network errors")
+	fs.IntVar(&opts.errorBudget, "llm-error-budget", 10, "failed LLM requests after which the run stops, 0 for no limit")
+	fs.StringVar(&opts.pricesFile, "prices", "", "JSON file of model prices per million tokens (default: $LLM_PRICES_FILE)")
+	fs.Float64Var(&opts.maxCost, "max-cost", 0, "stop the run once its LLM calls cost this many USD, 0 for no cap")
+	fs.BoolVar(&opts.noCache, "no-cache", false, "ask the LLM again instead of reusing cached responses (fresh responses are still cached)")
+	fs.StringVar(&opts.timezone, "tz", "", "timezone in which days start and end, e.g. Europe/Berlin (default: $REPORT_TIMEZONE, else UTC)")
+	fs.StringVar(&opts.formats, "format", strings.Join(reportFormats, ","), "comma-separated formats of multi-day reports: json, md and html")
+	fs.StringVar(&opts.verify, "verify", verifyLexical, "check summary claims against the tweets: off, lexical, or llm for a second LLM pass")
+	fs.StringVar(&opts.injection, "injection", injectionQuarantine, "tweets that look like prompt injection: off, flag, or quarantine to also withhold the worst from the LLM")
+	fs.StringVar(&opts.categories, "category", "", "only report on accounts in these comma-separated registry categories")
+	fs.StringVar(&opts.operators, "operator", "", "only report on accounts run by these comma-separated operators")
+	fs.StringVar(&opts.tags, "tag", "", "only report on accounts with any of these comma-separated tags")
+	fs.StringVar(&opts.groupBy, "group-by", "", "group tweet counts of multi-day reports by category, operator, model or tag")
+	fs.IntVar(&opts.baselineDays, "baseline-days", 28, "days of history before the window that alerts on posting cadence compare to, 0 for no alerts")
+	fs.StringVar(&opts.classifier, "classifier", "", "trained account classifier (default: $CLASSIFIER_FILE, else ./data/classifier.json, else built-in weights)")
+	fs.BoolVar(&opts.refresh, "refresh", false, "generate every day again instead of reusing stored daily reports")
+	addStoreFlags(fs, &opts.storeOptions)
+	return opts
+}
+
+func (o *reportOptions) newApp() (*App, error) {
+	formats, err := parseReportFormats(o.formats)
+	if err != nil {
+		return nil, err
+	}
+	return NewApp(context.Background(), Config{
+		Model:     o.model,
+		OutputDir: o.outputDir,
+		Store:     o.store,
+		StoreDir:  o.storeDir,
+		Provider:  o.provider,
+		NoLLM:     o.noLLM,
+		BaseURL:   o.baseURL,
+		Workers:   o.workers,
+		LLMRPM:    o.llmRPM,
+		CacheTTL:  o.cacheTTL,
+		NoCache:   o.noCache,
+
+		LLMRetries:     o.llmRetries,
+		LLMErrorBudget: o.errorBudget,
+		PricesFile:     o.pricesFile,
+		MaxCost:        o.maxCost,
+		Refresh:        o.refresh,
+		Timezone:       o.timezone,
+		Formats:        formats,
+		Verify:         o.verify,
+		Injection:      o.injection,
+		AccountFilter: AccountFilter{
+			Categories: splitList(o.categories),
+			Operators:  splitList(o.operators),
+			Tags:       splitList(o.tags),
+		},
+		GroupBy:        o.groupBy,
+		BaselineDays:   o.baselineDays,
+		ClassifierFile: o.classifier,
+	})
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// window resolves -start, -end and -days into a start date and a number of days.
+// Dates are in the location of now, which should be the App's.
+func (o *reportOptions) window(now time.Time) (time.Time, int, error) {
+	var start, end time.Time
+	var err error
+	if o.start != "" {
+		if start, err = time.ParseInLocation(dateLayout, o.start, now.Location()); err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid -start date %q: %v", o.start, err)
+		}
+	}
+	if o.end != "" {
+		if end, err = time.ParseInLocation(dateLayout, o.end, now.Location()); err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid -end date %q: %v", o.end, err)
+		}
+	}
+
+	switch {
+	case o.start != "" && o.end != "":
+		if end.Before(start) {
+			return time.Time{}, 0, fmt.Errorf("-end %s is before -start %s", o.end, o.start)
+		}
+		days := 0
+		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+			days++
+		}
+		return start, days, nil
+	case o.days < 1:
+		return time.Time{}, 0, fmt.Errorf("-days must be at least 1, got %d", o.days)
+	case o.start != "":
+		return start, o.days, nil
+	case o.end != "":
+		return end.AddDate(0, 0, -(o.days - 1)), o.days, nil
+	default:
+		// The last o.days complete days, ending yesterday
+		return now.AddDate(0, 0, -o.days), o.days, nil
+	}
+}
+
+func runReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	opts := addReportFlags(fs)
+	fs.Parse(args)
+
+	app, err := opts.newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	startDate, days, err := opts.window(time.Now().In(app.location))
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := app.GenerateReports(ctx, opts.accountsList, startDate, days); err != nil {
+		return fmt.Errorf("error generating reports: %w", err)
+	}
+
+	fmt.Println("Report generation completed successfully.")
+	return nil
+}
+
+func runDaily(args []string) error {
+	fs := flag.NewFlagSet("daily", flag.ExitOnError)
+	opts := addReportFlags(fs)
+	date := fs.String("date", "", "day to report on, YYYY-MM-DD (default: yesterday)")
+	fs.Parse(args)
+
+	app, err := opts.newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	targetDate := time.Now().In(app.location).AddDate(0, 0, -1)
+	if *date != "" {
+		if targetDate, err = time.ParseInLocation(dateLayout, *date, app.location); err != nil {
+			return fmt.Errorf("invalid -date %q: %v", *date, err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := app.GenerateDailyReportFile(ctx, opts.accountsList, targetDate); err != nil {
+		return fmt.Errorf("error generating daily report: %w", err)
+	}
+
+	fmt.Println("Daily report generation completed successfully.")
+	return nil
+}
+
+func runGraph(args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	opts := addReportFlags(fs)
+	opts.noLLM = true
+	formats := fs.String("graph-format", "json,graphml,gexf", "comma-separated formats of the graph: json, graphml and gexf")
+	internal := fs.Bool("internal", false, "only keep interactions between accounts of the list")
+	fs.Parse(args)
+
+	app, err := opts.newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	startDate, days, err := opts.window(time.Now().In(app.location))
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	graph, err := app.buildInteractionGraph(ctx, opts.accountsList, startDate, days, *internal)
+	if err != nil {
+		return fmt.Errorf("error building interaction graph: %v", err)
+	}
+	for _, format := range splitList(*formats) {
+		path, err := saveGraph(graph, format, opts.outputDir, app.reportName(opts.accountsList))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Graph saved to: %s\n", path)
+	}
+	printGraph(graph)
+	return nil
+}
+
+// printGraph prints the metrics and strongest edges of graph
+func printGraph(graph *Graph) {
+	fmt.Printf("\n%d accounts, %d edges, %.0f%% reciprocated, %d communities\n",
+		len(graph.Nodes), len(graph.Edges), 100*graph.Reciprocity, graph.Communities)
+	for i, edge := range graph.Edges {
+		if i == 10 {
+			fmt.Printf("  ... and %d more\n", len(graph.Edges)-i)
+			break
+		}
+		var kinds []string
+		for _, kind := range interactionKinds {
+			if weight := edge.Kinds[kind]; weight > 0 {
+				kinds = append(kinds, fmt.Sprintf("%d %s", weight, kind))
+			}
+		}
+		fmt.Printf("  @%s -> @%s: %s\n", edge.Source, edge.Target, strings.Join(kinds, ", "))
+	}
+	if change := graph.Change; change != nil {
+		fmt.Printf("Since %s to %s: %d new edges, %d gone, %d changed weight\n",
+			change.PreviousStart, change.PreviousEnd, len(change.NewEdges), len(change.GoneEdges), len(change.Changed))
+	}
+}
+
+func runDiscover(args []string) error {
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	opts := addReportFlags(fs)
+	fetchOpts := addFetchFlags(fs)
+	minAccounts := fs.Int("min-accounts", 2, "accounts of the list that must have interacted with a candidate")
+	limit := fs.Int("limit", 25, "candidates to score and keep, 0 for all")
+	fetch := fs.Bool("fetch", false, "fetch the timelines of the candidates before scoring them")
+	classify := fs.Bool("classify", false, "also ask the LLM whether each candidate is an agent")
+	candidatesDir := fs.String("candidates-dir", "./data/candidates", "directory where candidates files are written")
+	fs.Parse(args)
+
+	app, err := opts.newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	startDate, days, err := opts.window(time.Now().In(app.location))
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	app.resetUsage()
+
+	graph, err := app.buildInteractionGraph(ctx, opts.accountsList, startDate, days, false)
+	if err != nil {
+		return fmt.Errorf("error building interaction graph: %v", err)
+	}
+	reviewed, err := reviewedCandidates(*candidatesDir, opts.accountsList)
+	if err != nil {
+		return err
+	}
+	skip := make(map[string]bool)
+	for handle, status := range reviewed {
+		skip[handle] = status == candidateRejected || status == candidatePromoted
+	}
+	candidates := rankCandidates(graph, *minAccounts, *limit, skip)
+	for _, c := range candidates {
+		if reviewed[c.Handle] == candidateApproved {
+			c.Status = candidateApproved
+		}
+	}
+
+	if *fetch && len(candidates) > 0 {
+		fetcher, err := fetchOpts.newFetcher(app.store, opts.accountsList)
+		if err != nil {
+			return err
+		}
+		var handles []string
+		for _, c := range candidates {
+			handles = append(handles, c.Handle)
+		}
+		stats, err := fetcher.FetchAccounts(ctx, handles)
+		if err != nil {
+			return fmt.Errorf("error fetching candidates: %v", err)
+		}
+		fmt.Printf("Fetched %d candidates (%d failed): %d tweets, %d new\n", stats.Accounts, stats.Failed, stats.Fetched, stats.Inserted)
+	}
+
+	if err := app.scoreCandidates(ctx, candidates, startDate, startDate.AddDate(0, 0, days), *classify); err != nil {
+		return fmt.Errorf("error scoring candidates: %w", err)
+	}
+	path := candidatesPath(*candidatesDir, app.reportName(opts.accountsList), graph.StartDate, graph.EndDate)
+	file := &CandidatesFile{
+		AccountsList:         opts.accountsList,
+		GeneratedAt:          time.Now().UTC().Format(time.RFC3339),
+		StartDate:            graph.StartDate,
+		EndDate:              graph.EndDate,
+		Timezone:             graph.Timezone,
+		Classifier:           app.classifier.Description,
+		ClassifierCalibrated: app.classifier.calibrated(),
+		Candidates:           keepReviewed(path, candidates),
+	}
+	if err := saveCandidatesFile(path, file); err != nil {
+		return err
+	}
+
+	for _, c := range candidates {
+		fmt.Printf("  @%-15s score %6.3f  agent %.2f  %d interactions from %s\n",
+			c.Handle, c.Score, c.AgentScore, c.Interactions, strings.Join(c.InteractedBy, ", "))
+	}
+	fmt.Printf("%d candidates saved to: %s\n", len(candidates), path)
+	if !app.classifier.calibrated() {
+		fmt.Println("The classifier isn't trained, so its agent scores only rank candidates; they aren't probabilities.")
+	}
+	if cost := app.costReport(); cost != nil && *classify {
+		fmt.Println(cost.String())
+	}
+	fmt.Println("Set the status of candidates to approved or rejected, then run promote.")
+	return nil
+}
+
+func runClassify(args []string) error {
+	fs := flag.NewFlagSet("classify", flag.ExitOnError)
+	opts := addReportFlags(fs)
+	account := fs.String("account", "", "classify this handle instead of the accounts of the list")
+	trainAgents := fs.String("train-agents", "", "train the classifier on these comma-separated accounts lists of known agents")
+	trainHumans := fs.String("train-humans", "", "and on these comma-separated accounts lists of known people")
+	fs.Parse(args)
+
+	app, err := opts.newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	startDate, days, err := opts.window(time.Now().In(app.location))
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	since, until := dayQuery(nil, startDate).Since, dayQuery(nil, startDate.AddDate(0, 0, days-1)).Until
+
+	if *trainAgents != "" || *trainHumans != "" {
+		var labeled [2][]string
+		for i, lists := range []string{*trainAgents, *trainHumans} {
+			for _, list := range splitList(lists) {
+				handles, err := getAccounts(list)
+				if err != nil {
+					return err
+				}
+				labeled[i] = append(labeled[i], handles...)
+			}
+		}
+		model, err := app.trainClassifierOn(ctx, TweetQuery{Since: since, Until: until}, labeled[0], labeled[1])
+		if err != nil {
+			return fmt.Errorf("error training classifier: %v", err)
+		}
+		path := opts.classifier
+		if path == "" {
+			path = Config{}.withDefaults().ClassifierFile
+		}
+		if err := saveClassifierModel(path, model); err != nil {
+			return err
+		}
+		fmt.Printf("Classifier %s saved to: %s\n", model.Description, path)
+		fmt.Printf("Left-out accounts: Brier score %.3f, log loss %.3f, accuracy %.0f%%\n",
+			model.Evaluation.Brier, model.Evaluation.LogLoss, 100*model.Evaluation.Accuracy)
+		return nil
+	}
+
+	var classifications []AccountClassification
+	if *account != "" {
+		handle := normalizeHandle(*account)
+		classifications, err = app.classifyAccounts(ctx, TweetQuery{Since: since, Until: until}, []string{handle})
+	} else {
+		classifications, err = app.classifyList(ctx, opts.accountsList, startDate, days)
+	}
+	if err != nil {
+		return fmt.Errorf("error classifying accounts: %v", err)
+	}
+	fmt.Printf("Classifier: %s\n", app.classifier.Description)
+	if !app.classifier.calibrated() {
+		fmt.Println("Its scores only rank accounts by how agent-like they post; they aren't probabilities until it's trained.")
+	}
+	if len(classifications) == 0 {
+		fmt.Printf("No account has %d tweets in the window\n", minClassifierTweets)
+	}
+	for _, c := range classifications {
+		fmt.Printf("@%s: %.2f from %d tweets\n", c.Account, c.Score, c.Tweets)
+		for _, contribution := range c.Contributions {
+			fmt.Printf("  %-17s %7.3f  %+.2f\n", contribution.Feature, contribution.Value, contribution.Contribution)
+		}
+	}
+	return nil
+}
+
+func runPromote(args []string) error {
+	fs := flag.NewFlagSet("promote", flag.ExitOnError)
+	candidatesPath := fs.String("candidates", "", "candidates file written by discover")
+	accountsList := fs.String("to", "", "accounts list to add the approved candidates to (default: the list they were discovered from)")
+	fs.Parse(args)
+
+	if *candidatesPath == "" {
+		return fmt.Errorf("promote needs -candidates")
+	}
+	added, err := promoteCandidates(*candidatesPath, *accountsList)
+	if err != nil {
+		return fmt.Errorf("error promoting candidates: %v", err)
+	}
+	for _, handle := range added {
+		fmt.Printf("Added @%s\n", handle)
+	}
+	fmt.Printf("Promoted %d candidates\n", len(added))
+	return nil
+}
+
+// fetchOptions holds the flags that configure a Fetcher
+type fetchOptions struct {
+	source            string
+	sourceDir         string
+	requestsPerMinute int
+	burst             int
+	lookback          time.Duration
+}
+
+func addFetchFlags(fs *flag.FlagSet) *fetchOptions {
+	opts := &fetchOptions{}
+	fs.StringVar(&opts.source, "source", "http", "timeline source: http ($TIMELINE_API_URL) or file (a JSONL dump)")
+	fs.StringVar(&opts.sourceDir, "source-dir", "", "directory of the JSONL dump read by -source file")
+	fs.IntVar(&opts.requestsPerMinute, "rpm", 30, "maximum requests per minute to the timeline source")
+	fs.IntVar(&opts.burst, "burst", 5, "maximum requests sent in a burst")
+	fs.DurationVar(&opts.lookback, "lookback", 7*24*time.Hour, "how far back to fetch accounts with no stored tweets")
+	return opts
+}
+
+func (o *fetchOptions) newFetcher(store TweetStore, accountsList string) (*Fetcher, error) {
+	var source TimelineSource
+	switch o.source {
+	case "http":
+		var err error
+		if source, err = newHTTPTimelineSource(os.Getenv("TIMELINE_API_URL"), os.Getenv("TIMELINE_API_KEY")); err != nil {
+			return nil, err
+		}
+	case "file":
+		if o.sourceDir == "" {
+			return nil, fmt.Errorf("-source file needs -source-dir")
+		}
+		dump, err := newFileStore(o.sourceDir)
+		if err != nil {
+			return nil, err
+		}
+		source = &storeTimelineSource{name: "file " + o.sourceDir, store: dump}
+	default:
+		return nil, fmt.Errorf("unknown timeline source %q (want http or file)", o.source)
+	}
+
+	fetcher := NewFetcher(store, source, accountsList, o.requestsPerMinute, o.burst)
+	fetcher.lookback = o.lookback
+	return fetcher, nil
+}
+
+func runFetch(args []string) error {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	accountsList := fs.String("accounts", "ai-og", "accounts list to fetch")
+	fetchOpts := addFetchFlags(fs)
+	var storeOpts storeOptions
+	addStoreFlags(fs, &storeOpts)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	app, err := NewApp(ctx, Config{Store: storeOpts.store, StoreDir: storeOpts.storeDir})
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	fetcher, err := fetchOpts.newFetcher(app.store, *accountsList)
+	if err != nil {
+		return err
+	}
+	stats, err := fetcher.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("error fetching tweets: %v", err)
+	}
+
+	fmt.Printf("Fetched %d accounts (%d failed): %d tweets, %d new\n", stats.Accounts, stats.Failed, stats.Fetched, stats.Inserted)
+	return nil
+}
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	opts := addReportFlags(fs)
+	fetchOpts := addFetchFlags(fs)
+	noFetch := fs.Bool("no-fetch", false, "only make reports, for tweets fetched by something else")
+	fetchEvery := fs.Duration("fetch-every", time.Hour, "how often to fetch new tweets")
+	reportAfter := fs.Duration("report-after", 90*time.Minute, "how long after midnight to report on the day before")
+	weeklyOn := fs.String("weekly-on", "monday", "weekday on which to report on the last -days days")
+	statePath := fs.String("state", "", "checkpoint file (default: ./data/state/scheduler_<accounts>.json)")
+	fs.Parse(args)
+
+	weekday, err := parseWeekday(*weeklyOn)
+	if err != nil {
+		return err
+	}
+	if opts.days < 1 {
+		return fmt.Errorf("-days must be at least 1, got %d", opts.days)
+	}
+	if *statePath == "" {
+		*statePath = fmt.Sprintf("./data/state/scheduler_%s.json", opts.accountsList)
+	}
+
+	app, err := opts.newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	scheduler := &Scheduler{
+		app:          app,
+		accountsList: opts.accountsList,
+		location:     app.location,
+		fetchEvery:   *fetchEvery,
+		reportAfter:  *reportAfter,
+		weeklyOn:     weekday,
+		weeklyDays:   opts.days,
+		statePath:    *statePath,
+	}
+	if !*noFetch {
+		if scheduler.fetcher, err = fetchOpts.newFetcher(app.store, opts.accountsList); err != nil {
+			return err
+		}
+	}
+
+	// SIGTERM cancels the LLM calls in flight; the checkpoint only records finished work
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := scheduler.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	fmt.Println("Scheduler stopped.")
+	return nil
+}
+
+// parseWeekday parses an English weekday name, such as "monday"
+func parseWeekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", name)
+}
+
+func runAccounts(args []string) error {
+	fs := flag.NewFlagSet("accounts", flag.ExitOnError)
+	accountsList := fs.String("accounts", "ai-og", "accounts list to show")
+	all := fs.Bool("all", false, "show the available accounts lists instead")
+	fs.Parse(args)
+
+	if *all {
+		lists, err := listAccountsLists()
+		if err != nil {
+			return err
+		}
+		for _, list := range lists {
+			fmt.Println(list)
+		}
+		return nil
+	}
+
+	accounts, err := loadAccounts(*accountsList)
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		fmt.Println(account.describe())
+	}
+	return nil
+}
+
+func runSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	opts := addReportFlags(fs)
+	opts.noLLM = true
+	tweetID := fs.String("id", "", "show the tweet with this ID instead of searching")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: search [flags] [text]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *tweetID != "" {
+		app, err := opts.newApp()
+		if err != nil {
+			return err
+		}
+		defer app.Close()
+
+		tweet, err := app.store.GetTweet(context.Background(), *tweetID)
+		if errors.Is(err, ErrTweetNotFound) {
+			return fmt.Errorf("no tweet with ID %s", *tweetID)
+		} else if err != nil {
+			return err
+		}
+		printTweets([]Tweet{tweet})
+		return nil
+	}
+
+	// Without text, search lists every tweet in the window
+	query := strings.TrimSpace(strings.Join(fs.Args(), " "))
+
+	app, err := opts.newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	startDate, days, err := opts.window(time.Now().In(app.location))
+	if err != nil {
+		return err
+	}
+	since := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
+	until := since.AddDate(0, 0, days)
+	tweets, err := app.searchTweets(opts.accountsList, query, since, until)
+	if err != nil {
+		return fmt.Errorf("error searching tweets: %v", err)
+	}
+	printTweets(tweets)
+	fmt.Printf("%d tweets matching %q\n", len(tweets), query)
+	return nil
+}
+
+func printTweets(tweets []Tweet) {
+	for _, tweet := range tweets {
+		fmt.Printf("[%s] @%s (%s): %s\n", tweet.CreatedAt, tweet.Username, tweet.ID, tweet.Text)
+	}
+}
+
+// dumpBatchSize is how many tweets dump writes at a time
+const dumpBatchSize = 5000
+
+func runDump(args []string) error {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	opts := addReportFlags(fs)
+	opts.noLLM = true
+	to := fs.String("to", "./data/tweets", "directory of the file store to write to")
+	fs.Parse(args)
+
+	app, err := opts.newApp()
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	startDate, days, err := opts.window(time.Now().In(app.location))
+	if err != nil {
+		return err
+	}
+	query := dayQuery(nil, startDate)
+	query.Until = query.Since.AddDate(0, 0, days)
+	accounts, err := app.selectAccounts(opts.accountsList, time.Time{})
+	if err != nil {
+		return err
+	}
+	if len(accounts) == 0 {
+		return fmt.Errorf("no accounts in %s match the filter", opts.accountsList)
+	}
+	query.Accounts = accountHandles(accounts)
+
+	dump, err := newFileStore(*to)
+	if err != nil {
+		return err
+	}
+
+	// Tweets are streamed and written in batches, so a long window doesn't have to fit in memory
+	var batch []Tweet
+	dumped := 0
+	flush := func() error {
+		if _, err := dump.UpsertTweets(context.Background(), batch); err != nil {
+			return fmt.Errorf("error writing dump: %v", err)
+		}
+		dumped += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	err = app.store.EachTweet(context.Background(), query, func(tweet Tweet) error {
+		if batch = append(batch, tweet); len(batch) >= dumpBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error loading tweets: %v", err)
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Dumped %d tweets to %s\n", dumped, *to)
+	return nil
+}
+
+func runNotifyTest(args []string) error {
+	fs := flag.NewFlagSet("notify-test", flag.ExitOnError)
+	severity := fs.String("severity", severityWarning, "severity of the test alert: info, warning or critical")
+	fs.Parse(args)
+
+	if _, ok := severityRank[*severity]; !ok {
+		return fmt.Errorf("unknown alert severity %q (want info, warning or critical)", *severity)
+	}
+	if err := loadEnv(); err != nil {
+		return err
+	}
+	cfg := Config{}.withDefaults()
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %v", cfg.Timezone, err)
+	}
+	notifier, err := newNotifier(cfg, location)
+	if err != nil {
+		return err
+	}
+	return notifier.Test(context.Background(), []Alert{{
+		Kind:     "test",
+		Severity: *severity,
+		Account:  "test",
+		Date:     time.Now().In(location).Format(dateLayout),
+		Message:  "This is a test alert from twitter-cli",
+	}})
+}
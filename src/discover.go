@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Review statuses of a candidate. Reviewers edit pending candidates of a candidates file
+// into approved or rejected ones, and promoteCandidates adds the approved ones to a list,
+// marking those the list already had as duplicates.
+const (
+	candidatePending   = "pending"
+	candidateApproved  = "approved"
+	candidateRejected  = "rejected"
+	candidatePromoted  = "promoted"
+	candidateDuplicate = "duplicate"
+)
+
+const (
+	classifySampleSize = 30  // latest tweets of a candidate the LLM classifies it from
+	neutralAgentScore  = 0.5 // agent-likeness of a candidate nothing is known about
+)
+
+// Candidate is an account the list interacts with but doesn't watch
+type Candidate struct {
+	Handle         string                 `json:"handle"`
+	Status         string                 `json:"status"`
+	InteractedBy   []string               `json:"interacted_by"` // accounts of the list that interacted with it
+	Interactions   int                    `json:"interactions"`
+	Kinds          map[string]int         `json:"kinds"`                    // interactions by kind
+	Classification *AccountClassification `json:"classification,omitempty"` // nil without enough stored tweets
+	AgentScore     float64                `json:"agent_score"`              // agent-likeness from 0 to 1, from the classifier and the LLM
+	LLM            *ClassificationBox     `json:"llm,omitempty"`
+	Score          float64                `json:"score"` // what candidates are ranked by
+}
+
+// CandidatesFile is a discover run, for review
+type CandidatesFile struct {
+	AccountsList         string       `json:"accounts_list"`
+	GeneratedAt          string       `json:"generated_at"`
+	StartDate            string       `json:"start_date"`
+	EndDate              string       `json:"end_date"`
+	Timezone             string       `json:"timezone"`
+	Classifier           string       `json:"classifier,omitempty"`
+	ClassifierCalibrated bool         `json:"classifier_calibrated"` // false when the classifier's scores only rank candidates
+	Candidates           []*Candidate `json:"candidates"`
+}
+
+// rankCandidates returns the accounts of the graph that aren't in the list and that at least
+// minAccounts accounts of the list interacted with, most interacted with first, skipping
+// handles in skip. limit caps how many are returned, unless it's 0.
+func rankCandidates(graph *Graph, minAccounts int, limit int, skip map[string]bool) []*Candidate {
+	observed := make(map[string]bool)
+	for _, n := range graph.Nodes {
+		observed[n.ID] = n.Observed
+	}
+	candidates := make(map[string]*Candidate)
+	for _, edge := range graph.Edges {
+		if observed[edge.Target] || !observed[edge.Source] || skip[edge.Target] {
+			continue
+		}
+		c, ok := candidates[edge.Target]
+		if !ok {
+			c = &Candidate{Handle: edge.Target, Status: candidatePending, Kinds: make(map[string]int)}
+			candidates[edge.Target] = c
+		}
+		c.InteractedBy = append(c.InteractedBy, edge.Source)
+		c.Interactions += edge.Weight
+		for kind, weight := range edge.Kinds {
+			c.Kinds[kind] += weight
+		}
+	}
+
+	var ranked []*Candidate
+	for _, c := range candidates {
+		if len(c.InteractedBy) < minAccounts {
+			continue
+		}
+		sort.Strings(c.InteractedBy)
+		ranked = append(ranked, c)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if len(ranked[i].InteractedBy) != len(ranked[j].InteractedBy) {
+			return len(ranked[i].InteractedBy) > len(ranked[j].InteractedBy)
+		}
+		if ranked[i].Interactions != ranked[j].Interactions {
+			return ranked[i].Interactions > ranked[j].Interactions
+		}
+		return ranked[i].Handle < ranked[j].Handle
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// scoreCandidates scores the candidates with the account classifier from their tweets in
+// [since, until), also asks the LLM if classify is set, and sorts them by score. A trained
+// classifier's probability is averaged with the LLM's; an untrained one's score isn't a
+// probability, so the LLM's replaces it when there is one.
+func (a *App) scoreCandidates(ctx context.Context, candidates []*Candidate, since time.Time, until time.Time, classify bool) error {
+	if classify && a.llm == nil {
+		return fmt.Errorf("classifying candidates needs an LLM provider")
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// Stored usernames keep the case they were fetched with, but handles in the graph are
+	// lowercase, so the window is read whole rather than filtered by account
+	var handles []string
+	for _, c := range candidates {
+		handles = append(handles, c.Handle)
+	}
+	byHandle, err := a.tweetsByHandle(ctx, TweetQuery{Since: since, Until: until}, handles)
+	if err != nil {
+		return fmt.Errorf("failed to load candidate tweets: %v", err)
+	}
+
+	for _, c := range candidates {
+		tweets := byHandle[c.Handle]
+		c.AgentScore = neutralAgentScore
+		if c.Classification = a.classifier.classify(c.Handle, tweets); c.Classification != nil {
+			c.AgentScore = c.Classification.Score
+		}
+
+		if classify && len(tweets) > 0 {
+			// The store returns tweets newest first
+			var lines []string
+			for _, tweet := range tweets[:min(len(tweets), classifySampleSize)] {
+				lines = append(lines, formatCitedLine([]string{tweet.ID}, tweet.Text))
+			}
+			classifyCtx := withLLMStage(ctx, llmStage{Stage: "discovery", Account: c.Handle})
+			classification, err := ClassifyAccount(classifyCtx, a.llm, a.model, c.Handle, strings.Join(lines, "\n"))
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if isRunFatal(err) {
+					return err
+				}
+				fmt.Printf("Warning: failed to classify @%s: %v\n", c.Handle, err)
+			} else {
+				c.LLM = &classification
+				probability := math.Min(math.Max(classification.Probability, 0), 1)
+				if c.Classification != nil && c.Classification.Calibrated {
+					c.AgentScore = (c.AgentScore + probability) / 2
+				} else {
+					c.AgentScore = probability
+				}
+			}
+		}
+
+		// How much the list interacts with the candidate, weighted by how agent-like it looks
+		reach := float64(len(c.InteractedBy)) + math.Log2(1+float64(c.Interactions))
+		c.AgentScore = math.Round(c.AgentScore*1000) / 1000
+		c.Score = math.Round(reach*c.AgentScore*1000) / 1000
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	return nil
+}
+
+// candidatesPath is where the candidates of a discover run over a window are written
+func candidatesPath(dir string, name string, startDate string, endDate string) string {
+	return filepath.Join(dir, fmt.Sprintf("%s_%s_to_%s.json", name, startDate, endDate))
+}
+
+func loadCandidatesFile(path string) (*CandidatesFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read candidates file: %v", err)
+	}
+	var file CandidatesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse candidates file %s: %v", path, err)
+	}
+	return &file, nil
+}
+
+func saveCandidatesFile(path string, file *CandidatesFile) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create candidates directory: %v", err)
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal candidates: %v", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write candidates file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write candidates file: %v", err)
+	}
+	return nil
+}
+
+// reviewedCandidates returns the statuses given to candidates of the list in earlier
+// candidates files in dir, so that rejected and promoted handles aren't suggested again
+// and a rerun over the same window keeps the reviews made so far
+func reviewedCandidates(dir string, accountsList string) (map[string]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list candidates files: %v", err)
+	}
+	statuses := make(map[string]string)
+	for _, path := range paths {
+		file, err := loadCandidatesFile(path)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+			continue
+		}
+		if file.AccountsList != accountsList {
+			continue
+		}
+		for _, c := range file.Candidates {
+			if c.Status != candidatePending && c.Status != "" {
+				statuses[c.Handle] = c.Status
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// keepReviewed appends to candidates the reviewed candidates of the candidates file at path
+// that they leave out, so that rerunning discover over a window doesn't lose its reviews
+func keepReviewed(path string, candidates []*Candidate) []*Candidate {
+	if _, err := os.Stat(path); err != nil {
+		return candidates
+	}
+	file, err := loadCandidatesFile(path)
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return candidates
+	}
+	for _, c := range file.Candidates {
+		if c.Status == candidatePending || c.Status == "" {
+			continue
+		}
+		if !slices.ContainsFunc(candidates, func(other *Candidate) bool { return other.Handle == c.Handle }) {
+			candidates = append(candidates, c)
+		}
+	}
+	return candidates
+}
+
+// promoteCandidates adds the approved candidates of the candidates file at path to an
+// accounts list, and marks them promoted, or duplicate if the list already has them.
+// Registries get entries tagged "discovered", with the LLM's category if there is one; plain
+// .txt lists get a line per handle. Stored daily reports and interactions of the list are
+// made again by the next run, since their fingerprints cover the accounts.
+func promoteCandidates(path string, accountsList string) ([]string, error) {
+	file, err := loadCandidatesFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if accountsList == "" {
+		accountsList = file.AccountsList
+	}
+	var approved []*Candidate
+	for _, c := range file.Candidates {
+		if c.Status == candidateApproved {
+			approved = append(approved, c)
+		}
+	}
+	if len(approved) == 0 {
+		return nil, nil
+	}
+
+	// The list may be new
+	var existing []Account
+	lists, err := listAccountsLists()
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(lists, accountsList) {
+		if existing, err = loadAccounts(accountsList); err != nil {
+			return nil, err
+		}
+	}
+	known := make(map[string]bool)
+	for _, account := range existing {
+		known[strings.ToLower(account.Handle)] = true
+	}
+	var added []Account
+	for _, c := range approved {
+		if known[c.Handle] {
+			fmt.Printf("@%s is already in %s\n", c.Handle, accountsList)
+			c.Status = candidateDuplicate
+			continue
+		}
+		c.Status = candidatePromoted
+		known[c.Handle] = true
+		account := Account{Handle: c.Handle, Tags: []string{"discovered"}}
+		if c.LLM != nil && c.LLM.IsAgent {
+			account.Category = c.LLM.Category
+		}
+		added = append(added, account)
+	}
+
+	if err := appendAccounts(accountsList, added); err != nil {
+		return nil, err
+	}
+	if err := saveCandidatesFile(path, file); err != nil {
+		return nil, err
+	}
+	return accountHandles(added), nil
+}
+
+// appendAccounts adds accounts to the list: to data/<list>.json if it exists or if there is
+// no data/<list>.txt, and otherwise to the .txt file
+func appendAccounts(accountsList string, accounts []Account) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+	registryPath := filepath.Join(accountsDir, accountsList+".json")
+	textPath := filepath.Join(accountsDir, accountsList+".txt")
+	if _, err := os.Stat(registryPath); errors.Is(err, os.ErrNotExist) {
+		if existing, err := os.ReadFile(textPath); err == nil {
+			text, err := os.OpenFile(textPath, os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				return fmt.Errorf("failed to open accounts file: %v", err)
+			}
+			var lines strings.Builder
+			if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+				lines.WriteString("\n")
+			}
+			for _, account := range accounts {
+				lines.WriteString(account.Handle + "\n")
+			}
+			if _, err := text.WriteString(lines.String()); err != nil {
+				text.Close()
+				return fmt.Errorf("failed to write accounts file: %v", err)
+			}
+			return text.Close()
+		}
+	}
+
+	var registry accountsRegistry
+	data, err := os.ReadFile(registryPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error reading accounts file: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &registry); err != nil {
+			return fmt.Errorf("error parsing accounts file %s.json: %v", accountsList, err)
+		}
+	}
+	registry.Accounts = append(registry.Accounts, accounts...)
+	data, err = json.MarshalIndent(registry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal accounts: %v", err)
+	}
+	tmp := registryPath + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write accounts file: %v", err)
+	}
+	if err := os.Rename(tmp, registryPath); err != nil {
+		return fmt.Errorf("failed to write accounts file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRankCandidates(t *testing.T) {
+	// alice and bob are in the list; x, y and z aren't
+	graph := &Graph{
+		Nodes: []*GraphNode{
+			{ID: "alice", Observed: true}, {ID: "bob", Observed: true},
+			{ID: "x"}, {ID: "y"}, {ID: "z"},
+		},
+		Edges: []*GraphEdge{
+			{Source: "alice", Target: "x", Weight: 3, Kinds: map[string]int{"reply": 3}},
+			{Source: "bob", Target: "x", Weight: 1, Kinds: map[string]int{"mention": 1}},
+			{Source: "alice", Target: "y", Weight: 5, Kinds: map[string]int{"quote": 5}},
+			{Source: "bob", Target: "z", Weight: 1, Kinds: map[string]int{"reply": 1}},
+			{Source: "alice", Target: "z", Weight: 1, Kinds: map[string]int{"reply": 1}},
+			// Interactions within the list, or from outside it, don't make candidates
+			{Source: "bob", Target: "alice", Weight: 9, Kinds: map[string]int{"reply": 9}},
+			{Source: "x", Target: "y", Weight: 9, Kinds: map[string]int{"reply": 9}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		minAccounts int
+		limit       int
+		skip        map[string]bool
+		want        []string
+	}{
+		{"most accounts first", 1, 0, nil, []string{"x", "z", "y"}},
+		{"min accounts", 2, 0, nil, []string{"x", "z"}},
+		{"limit", 1, 2, nil, []string{"x", "z"}},
+		{"skip reviewed", 1, 0, map[string]bool{"x": true}, []string{"z", "y"}},
+		{"none", 3, 0, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := rankCandidates(graph, tt.minAccounts, tt.limit, tt.skip)
+			var handles []string
+			for _, c := range candidates {
+				handles = append(handles, c.Handle)
+				if c.Status != candidatePending {
+					t.Errorf("@%s has status %q, want pending", c.Handle, c.Status)
+				}
+			}
+			if !slices.Equal(handles, tt.want) {
+				t.Errorf("candidates = %v, want %v", handles, tt.want)
+			}
+		})
+	}
+
+	x := rankCandidates(graph, 1, 1, nil)[0]
+	if !slices.Equal(x.InteractedBy, []string{"alice", "bob"}) || x.Interactions != 4 || !maps.Equal(x.Kinds, map[string]int{"reply": 3, "mention": 1}) {
+		t.Errorf("x = %+v, want interactions from alice and bob added up by kind", x)
+	}
+}
+
+func TestReviewedCandidatesRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := candidatesPath(dir, "test", "2025-01-01", "2025-01-07")
+	files := map[string]*CandidatesFile{
+		path: {AccountsList: "test", Candidates: []*Candidate{
+			{Handle: "x", Status: candidateApproved},
+			{Handle: "y", Status: candidatePending},
+			{Handle: "z", Status: candidateRejected},
+		}},
+		candidatesPath(dir, "test", "2024-12-25", "2024-12-31"): {AccountsList: "test", Candidates: []*Candidate{
+			{Handle: "w", Status: candidatePromoted},
+		}},
+		candidatesPath(dir, "other", "2025-01-01", "2025-01-07"): {AccountsList: "other", Candidates: []*Candidate{
+			{Handle: "v", Status: candidateRejected},
+		}},
+	}
+	for path, file := range files {
+		if err := saveCandidatesFile(path, file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statuses, err := reviewedCandidates(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"x": candidateApproved, "z": candidateRejected, "w": candidatePromoted}
+	if !maps.Equal(statuses, want) {
+		t.Errorf("reviewed candidates = %v, want %v", statuses, want)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		candidates []string
+		want       []string
+	}{
+		{"rerun keeps the reviews", path, []string{"y", "u"}, []string{"y", "u", "x", "z"}},
+		{"reviewed candidates aren't repeated", path, []string{"x"}, []string{"x", "z"}},
+		{"first run", filepath.Join(dir, "missing.json"), []string{"y"}, []string{"y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var candidates []*Candidate
+			for _, handle := range tt.candidates {
+				candidates = append(candidates, &Candidate{Handle: handle, Status: candidatePending})
+			}
+			var handles []string
+			for _, c := range keepReviewed(tt.path, candidates) {
+				handles = append(handles, c.Handle)
+			}
+			if !slices.Equal(handles, tt.want) {
+				t.Errorf("kept %v, want %v", handles, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromoteCandidates(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		to        string
+		want      []string // the accounts of the list afterwards
+		wantAdded []string
+	}{
+		{"text list", map[string]string{"test.txt": "alice\nbob"}, "", []string{"alice", "bob", "x"}, []string{"x"}},
+		{"registry", map[string]string{"test.json": `{"accounts": [{"handle": "alice"}, {"handle": "bob"}]}`}, "", []string{"alice", "bob", "x"}, []string{"x"}},
+		{"new list", map[string]string{"test.txt": "alice\nbob\n"}, "found", []string{"x", "alice"}, []string{"x", "alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestAccountsDir(t, tt.files)
+			path := filepath.Join(t.TempDir(), "candidates.json")
+			err := saveCandidatesFile(path, &CandidatesFile{AccountsList: "test", Candidates: []*Candidate{
+				{Handle: "x", Status: candidateApproved},
+				{Handle: "alice", Status: candidateApproved},
+				{Handle: "y", Status: candidateRejected},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			list := tt.to
+			if list == "" {
+				list = "test"
+			}
+			added, err := promoteCandidates(path, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(added, tt.wantAdded) {
+				t.Errorf("added %v, want %v", added, tt.wantAdded)
+			}
+			accounts, err := loadAccounts(list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if handles := accountHandles(accounts); !slices.Equal(handles, tt.want) {
+				t.Errorf("%s has %v, want %v", list, handles, tt.want)
+			}
+
+			file, err := loadCandidatesFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			statuses := make(map[string]string)
+			for _, c := range file.Candidates {
+				statuses[c.Handle] = c.Status
+			}
+			wantStatuses := map[string]string{"x": candidatePromoted, "alice": candidateDuplicate, "y": candidateRejected}
+			if tt.to == "found" {
+				// alice isn't in the new list yet
+				wantStatuses["alice"] = candidatePromoted
+			}
+			if !maps.Equal(statuses, wantStatuses) {
+				t.Errorf("statuses = %v, want %v", statuses, wantStatuses)
+			}
+		})
+	}
+}
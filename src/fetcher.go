@@ -3,76 +3,189 @@ package main
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
-	"slices"
-	"strings"
+	"net/http"
 	"time"
-
-	"github.com/jackc/pgx/v5"
-	"github.com/joho/godotenv"
 )
 
-func getAccounts(accountsList string) ([]string, error){
-	accountsDir := "./data"
-	accountsPath := filepath.Join(accountsDir, accountsList+".txt")
-	accountsData, err := os.ReadFile(accountsPath)
+// searchTweets returns tweets from the accounts list whose text contains query (case-insensitive),
+// created in the half-open interval [since, until)
+func (a *App) searchTweets(accountsList string, query string, since time.Time, until time.Time) ([]Tweet, error) {
+	accounts, err := a.selectAccounts(accountsList, time.Time{})
 	if err != nil {
-		return []string{}, fmt.Errorf("error reading accounts file: %v", err)
+		return []Tweet{}, fmt.Errorf("didn't get accounts: %v", err)
+	}
+	// An empty query would match every account
+	if len(accounts) == 0 {
+		return []Tweet{}, nil
 	}
-	accounts := strings.Split(strings.TrimSpace(string(accountsData)), "\n")
 
-	// Filter out empty and commented accounts
-	var validAccounts []string
-	for _, account := range accounts {
-		if account = strings.TrimSpace(account); account != "" && !strings.HasPrefix(account, "#") {
-			validAccounts = append(validAccounts, account)
+	return a.store.QueryTweets(context.Background(), TweetQuery{
+		Accounts: accountHandles(accounts),
+		Since:    since,
+		Until:    until,
+		Text:     query,
+	})
+}
+
+// NewFetcher creates a Fetcher that pulls the timelines of accountsList from source into store,
+// making at most requestsPerMinute requests to the source, in bursts of up to burst requests
+func NewFetcher(store TweetStore, source TimelineSource, accountsList string, requestsPerMinute int, burst int) *Fetcher {
+	if requestsPerMinute < 1 {
+		requestsPerMinute = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Fetcher{
+		client:       &http.Client{Timeout: 30 * time.Second},
+		rateLimiter:  make(chan time.Time, burst),
+		accountsList: accountsList,
+		source:       source,
+		store:        store,
+		interval:     time.Minute / time.Duration(requestsPerMinute),
+		lookback:     7 * 24 * time.Hour,
+	}
+}
+
+// FetchStats counts what a fetch run did
+type FetchStats struct {
+	Accounts int // accounts fetched successfully
+	Failed   int // accounts whose fetch failed
+	Fetched  int // tweets returned by the source, after dedupe
+	Inserted int // tweets that were not in the store before
+}
+
+// refillRateLimiter adds a token to the bucket every interval until ctx is done.
+// The bucket starts full, so the first burst requests go out immediately.
+func (f *Fetcher) refillRateLimiter(ctx context.Context) {
+	for len(f.rateLimiter) < cap(f.rateLimiter) {
+		f.rateLimiter <- time.Now()
+	}
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-ticker.C:
+			select {
+			case f.rateLimiter <- t:
+			default: // bucket full
+			}
 		}
 	}
-	return validAccounts, nil
 }
 
-func (a *App) loadTweets(accountsList string) ([]Tweet, error) {
-	if err := godotenv.Load(".env"); err != nil {
-		return []Tweet{}, fmt.Errorf("error loading .env file: %v", err)
+// wait takes a token from the rate limiter
+func (f *Fetcher) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-f.rateLimiter:
+		return nil
 	}
+}
 
-	url := os.Getenv("DATABASE_POOL_URL")
-	if url == "" {
-		return []Tweet{}, fmt.Errorf("DATABASE_POOL_URL environment variable not set")
+// Run fetches new tweets for every account in the list once
+func (f *Fetcher) Run(ctx context.Context) (FetchStats, error) {
+	accounts, err := getAccounts(f.accountsList)
+	if err != nil {
+		return FetchStats{}, fmt.Errorf("didn't get accounts: %v", err)
 	}
+	return f.FetchAccounts(ctx, accounts)
+}
+
+// FetchAccounts fetches new tweets for each of accounts once. A failing account is
+// reported and skipped, so one suspended account doesn't stop the others.
+func (f *Fetcher) FetchAccounts(ctx context.Context, accounts []string) (FetchStats, error) {
+	var stats FetchStats
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go f.refillRateLimiter(ctx)
 
-	ctx := context.Background()
-	conn, err := pgx.Connect(ctx, url)
+	for _, account := range accounts {
+		if err := f.wait(ctx); err != nil {
+			return stats, err
+		}
+		fetched, inserted, err := f.fetchAccount(ctx, account)
+		if err != nil {
+			if ctx.Err() != nil {
+				return stats, ctx.Err()
+			}
+			fmt.Printf("Warning: failed to fetch @%s from %s: %v\n", account, f.source.Name(), err)
+			stats.Failed++
+			continue
+		}
+		fmt.Printf("@%s: %d tweets fetched, %d new\n", account, fetched, inserted)
+		stats.Accounts++
+		stats.Fetched += fetched
+		stats.Inserted += inserted
+	}
+
+	return stats, nil
+}
+
+// fetchAccount fetches the tweets of account newer than the latest one stored, and upserts them
+func (f *Fetcher) fetchAccount(ctx context.Context, account string) (int, int, error) {
+	since := time.Now().Add(-f.lookback)
+	latest, err := f.store.QueryTweets(ctx, TweetQuery{Accounts: []string{account}, Limit: 1})
 	if err != nil {
-		return []Tweet{}, fmt.Errorf("in client, failed to connect to database: %v", err)
+		return 0, 0, err
+	}
+	known := make(map[string]bool)
+	if len(latest) > 0 {
+		if createdAt, err := parseTweetTime(latest[0].CreatedAt); err == nil {
+			since = createdAt
+		}
+		// The tweets of the latest stored second, which the source sends again
+		stored, err := f.store.QueryTweets(ctx, TweetQuery{Accounts: []string{account}, Since: since})
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, tweet := range stored {
+			known[tweet.ID] = true
+		}
 	}
-	defer conn.Close(ctx)
 
-	rows, err := conn.Query(ctx, "SELECT tweet_id, tweet_text, username, created_at FROM tweets0x001 WHERE created_at >= NOW() - INTERVAL '1 day' ORDER BY created_at DESC")
+	tweets, err := f.source.FetchTimeline(ctx, f.client, account, since, known)
 	if err != nil {
-		return []Tweet{}, fmt.Errorf("failed to query tweets: %v", err)
+		return 0, 0, err
+	}
+	tweets = dedupeTweets(tweets)
+	fetchedAt := time.Now().UTC().Format(time.RFC3339)
+	for i := range tweets {
+		if tweets[i].Username == "" {
+			tweets[i].Username = account
+		}
+		if tweets[i].FetchedAt == "" {
+			tweets[i].FetchedAt = fetchedAt
+		}
+	}
+	if len(tweets) == 0 {
+		return 0, 0, nil
 	}
-	defer rows.Close()
 
-	validAccounts, err := getAccounts(accountsList)
+	inserted, err := f.store.UpsertTweets(ctx, tweets)
 	if err != nil {
-		return []Tweet{}, fmt.Errorf("didn't get accounts: %v", err)
+		return len(tweets), inserted, err
 	}
+	return len(tweets), inserted, nil
+}
 
-	var sources []Tweet
-	for rows.Next() {
-		var s Tweet
-		var date time.Time
-		err := rows.Scan(&s.ID, &s.Text, &s.Username, &date)
-		s.CreatedAt = date.Format("2006-01-02 15:04:05")
-		if err != nil {
-			return []Tweet{}, fmt.Errorf("failed to scan row: %v", err)
+// dedupeTweets drops repeated tweet_ids, keeping the last copy of each
+func dedupeTweets(tweets []Tweet) []Tweet {
+	index := make(map[string]int)
+	var unique []Tweet
+	for _, tweet := range tweets {
+		if tweet.ID == "" {
+			continue
 		}
-		if slices.Contains(validAccounts, s.Username) {
-			sources = append(sources, s)
+		if i, ok := index[tweet.ID]; ok {
+			unique[i] = tweet
+			continue
 		}
+		index[tweet.ID] = len(unique)
+		unique = append(unique, tweet)
 	}
-
-	return sources, nil
+	return unique
 }
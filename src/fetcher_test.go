@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// timelineAPI stubs the timeline API: pages[username][cursor] is the page at cursor,
+// and accounts without pages fail
+type timelineAPI struct {
+	mu       sync.Mutex
+	pages    map[string]map[string]TimelineResponse
+	requests []string // username and cursor of each request
+}
+
+func (api *timelineAPI) client(t *testing.T) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if got := req.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if _, err := time.Parse(time.RFC3339, req.URL.Query().Get("since")); err != nil {
+			t.Errorf("since = %q: %v", req.URL.Query().Get("since"), err)
+		}
+		username := strings.TrimPrefix(req.URL.Path, "/")
+		cursor := req.URL.Query().Get("cursor")
+		api.mu.Lock()
+		api.requests = append(api.requests, username+" "+cursor)
+		api.mu.Unlock()
+
+		page, ok := api.pages[username][cursor]
+		if !ok {
+			return &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Status:     "500 Internal Server Error",
+				Body:       io.NopCloser(strings.NewReader("account suspended")),
+			}, nil
+		}
+		body, err := json.Marshal(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Body:       io.NopCloser(strings.NewReader(string(body))),
+		}, nil
+	})}
+}
+
+func TestFetcherFetchAccounts(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	tweet := func(id string, age time.Duration) Tweet {
+		return Tweet{ID: id, Text: "tweet " + id, CreatedAt: now.Add(-age).Format(time.RFC3339)}
+	}
+	// alice's timeline, newest first, over three pages; a0 is older than the lookback
+	a := []Tweet{
+		tweet("a0", 8*24*time.Hour),
+		tweet("a1", 5*24*time.Hour),
+		tweet("a2", 3*24*time.Hour),
+		tweet("a3", 2*24*time.Hour),
+		tweet("a4", 24*time.Hour),
+		tweet("a5", time.Hour),
+	}
+	pages := map[string]map[string]TimelineResponse{
+		"alice": {
+			"":   {Timeline: []Tweet{a[5], a[4]}, Next: "p2"},
+			"p2": {Timeline: []Tweet{a[3], a[2]}, Next: "p3"},
+			"p3": {Timeline: []Tweet{a[1], a[0]}, Next: "p4"},
+			"p4": {Timeline: []Tweet{tweet("a-1", 9*24*time.Hour)}},
+		},
+	}
+	stored := func(tweets ...Tweet) []Tweet {
+		for i := range tweets {
+			tweets[i].Username = "alice"
+			tweets[i].FetchedAt = now.Add(-time.Minute).Format(time.RFC3339)
+			tweets[i].Raw = json.RawMessage(`{}`)
+		}
+		return tweets
+	}
+
+	tests := []struct {
+		name         string
+		stored       []Tweet
+		accounts     []string
+		wantRequests []string
+		wantStats    FetchStats
+		wantStored   []string
+	}{
+		{
+			name:         "pages back to the lookback",
+			accounts:     []string{"alice"},
+			wantRequests: []string{"alice ", "alice p2", "alice p3"},
+			wantStats:    FetchStats{Accounts: 1, Fetched: 5, Inserted: 5},
+			wantStored:   []string{"a5", "a4", "a3", "a2", "a1"},
+		},
+		{
+			name:         "stops at the page with a stored tweet",
+			stored:       stored(a[2]),
+			accounts:     []string{"alice"},
+			wantRequests: []string{"alice ", "alice p2"},
+			wantStats:    FetchStats{Accounts: 1, Fetched: 4, Inserted: 3},
+			wantStored:   []string{"a5", "a4", "a3", "a2"},
+		},
+		{
+			name:         "up to date",
+			stored:       stored(a[5], a[4]),
+			accounts:     []string{"alice"},
+			wantRequests: []string{"alice "},
+			wantStats:    FetchStats{Accounts: 1, Fetched: 1},
+			wantStored:   []string{"a5", "a4"},
+		},
+		{
+			name:         "a failing account doesn't stop the others",
+			stored:       stored(a[4]),
+			accounts:     []string{"bob", "alice"},
+			wantRequests: []string{"bob ", "alice "},
+			wantStats:    FetchStats{Accounts: 1, Failed: 1, Fetched: 2, Inserted: 1},
+			wantStored:   []string{"a5", "a4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestFileStore(t, tt.stored)
+			source, err := newHTTPTimelineSource("https://timeline.example/{username}?since={since}", "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			api := &timelineAPI{pages: pages}
+			fetcher := NewFetcher(store, source, "test", 6000, len(tt.accounts))
+			fetcher.client = api.client(t)
+
+			stats, err := fetcher.FetchAccounts(context.Background(), tt.accounts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats != tt.wantStats {
+				t.Errorf("stats = %+v, want %+v", stats, tt.wantStats)
+			}
+			if !slices.Equal(api.requests, tt.wantRequests) {
+				t.Errorf("requests = %q, want %q", api.requests, tt.wantRequests)
+			}
+
+			tweets, err := store.QueryTweets(context.Background(), TweetQuery{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, tweet := range tweets {
+				ids = append(ids, tweet.ID)
+				if tweet.Username != "alice" || tweet.FetchedAt == "" || len(tweet.Raw) == 0 {
+					t.Errorf("tweet %s = %+v, want its username, fetch time and raw payload", tweet.ID, tweet)
+				}
+			}
+			if !slices.Equal(ids, tt.wantStored) {
+				t.Errorf("stored %q, want %q", ids, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestHTTPTimelineSourcePageURL(t *testing.T) {
+	since := time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		template string
+		cursor   string
+		want     string
+	}{
+		{"https://api.example/{username}", "", "https://api.example/al%20ice"},
+		{"https://api.example/{username}", "c 2", "https://api.example/al%20ice?cursor=c+2"},
+		{"https://api.example/{username}?since={since}", "c2", "https://api.example/al%20ice?since=2025-01-06T09%3A00%3A00Z&cursor=c2"},
+		{"https://api.example/{username}?page={cursor}", "", "https://api.example/al%20ice?page="},
+		{"https://api.example/{username}?page={cursor}", "c2", "https://api.example/al%20ice?page=c2"},
+	}
+	for _, tt := range tests {
+		source, err := newHTTPTimelineSource(tt.template, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := source.pageURL("al ice", since, tt.cursor); got != tt.want {
+			t.Errorf("pageURL(%q, %q) = %q, want %q", tt.template, tt.cursor, got, tt.want)
+		}
+	}
+}
+
+func TestDedupeTweets(t *testing.T) {
+	tweets := dedupeTweets([]Tweet{
+		{ID: "1", Text: "first"},
+		{ID: ""},
+		{ID: "2", Text: "second"},
+		{ID: "1", Text: "first, edited"},
+	})
+	want := []Tweet{{ID: "1", Text: "first, edited"}, {ID: "2", Text: "second"}}
+	if len(tweets) != len(want) {
+		t.Fatalf("got %+v, want %+v", tweets, want)
+	}
+	for i := range want {
+		if tweets[i].ID != want[i].ID || tweets[i].Text != want[i].Text {
+			t.Errorf("tweet %d = %+v, want %+v", i, tweets[i], want[i])
+		}
+	}
+}
@@ -3,147 +3,143 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"slices"
+	"sort"
 	"strings"
 	"time"
-
-	"github.com/jackc/pgx/v5"
-	"github.com/joho/godotenv"
 )
 
 type DailyReport struct {
 	Date           string          `json:"date"`
+	Timezone       string          `json:"timezone"` // the day runs from midnight to midnight in this zone
 	TotalTweets    int             `json:"total_tweets"`
 	AccountReports []AccountReport `json:"account_reports"`
+	FlaggedTweets  []FlaggedTweet  `json:"flagged_tweets,omitempty"` // tweets that look like prompt injection
+	// Set when the report is stored for reuse; Generator is the LLM provider and model, and
+	// Fingerprint covers the accounts and options it was made with (see reportFingerprint)
+	GeneratedAt string `json:"generated_at,omitempty"`
+	Generator   string `json:"generator,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
 }
 
 type WeeklyReport struct {
-	StartDate      string        `json:"start_date"`
-	EndDate        string        `json:"end_date"`
-	DailyReports   []DailyReport `json:"daily_reports"`
-	OverallSummary string        `json:"overall_summary"`
-	TotalTweets    int           `json:"total_tweets"`
+	StartDate            string                  `json:"start_date"`
+	EndDate              string                  `json:"end_date"`
+	Timezone             string                  `json:"timezone"`
+	DailyReports         []DailyReport           `json:"daily_reports"`
+	OverallSummary       string                  `json:"overall_summary"`
+	TotalTweets          int                     `json:"total_tweets"`
+	DegradedSections     []DegradedSection       `json:"degraded_sections,omitempty"`
+	FlaggedTweets        []FlaggedTweet          `json:"flagged_tweets,omitempty"`
+	Cost                 *CostReport             `json:"cost,omitempty"`           // nil when no LLM is configured
+	AccountFilter        string                  `json:"account_filter,omitempty"` // the registry fields accounts were selected by
+	GroupBy              string                  `json:"group_by,omitempty"`
+	Groups               []AccountGroup          `json:"groups,omitempty"`
+	Alerts               []Alert                 `json:"alerts,omitempty"`                // unusual posting cadence, most severe first
+	Classifier           string                  `json:"classifier,omitempty"`            // which account classifier scored the accounts
+	ClassifierCalibrated bool                    `json:"classifier_calibrated,omitempty"` // trained, so its scores are probabilities and not only rankings
+	Classifications      []AccountClassification `json:"classifications,omitempty"`       // how agent-like each account posts, most first
 }
 
+
 type AccountReport struct {
-	Username   string  `json:"username"`
-	TweetCount int     `json:"tweet_count"`
-	Summary    string  `json:"summary"`
-	Tweets     []Tweet `json:"tweets"`
+	Username          string  `json:"username"`
+	TweetCount        int     `json:"tweet_count"`
+	Summary           string  `json:"summary"`
+	Claims            []Claim `json:"claims,omitempty"` // what the summary claims, citing tweet IDs
+	UnsupportedClaims int     `json:"unsupported_claims,omitempty"`
+	Degraded          bool    `json:"degraded,omitempty"` // the LLM failed, and Summary is only a count
+	Error             string  `json:"error,omitempty"`
+	Tweets            []Tweet `json:"tweets"`
 }
 
-// loadTweetsForDay fetches tweets for a specific day from the database
-func (a *App) loadTweetsForDay(accountsList string, targetDate time.Time) ([]Tweet, error) {
-	if err := godotenv.Load(".env"); err != nil {
-		return []Tweet{}, fmt.Errorf("error loading .env file: %v", err)
-	}
-
-	url := os.Getenv("DATABASE_POOL_URL")
-	if url == "" {
-		return []Tweet{}, fmt.Errorf("DATABASE_POOL_URL environment variable not set")
-	}
+// DegradedSection is a part of a report written without the LLM, because the LLM failed
+type DegradedSection struct {
+	Section string `json:"section"` // "account" or "overall_summary"
+	Date    string `json:"date,omitempty"`
+	Account string `json:"account,omitempty"`
+	Error   string `json:"error"`
+}
 
-	ctx := context.Background()
-	conn, err := pgx.Connect(ctx, url)
+// loadTweetsForDay fetches tweets for a specific day from the tweet store
+func (a *App) loadTweetsForDay(ctx context.Context, accountsList string, targetDate time.Time) ([]Tweet, error) {
+	accounts, err := a.selectAccounts(accountsList, targetDate)
 	if err != nil {
-		return []Tweet{}, fmt.Errorf("failed to connect to database: %v", err)
+		return []Tweet{}, fmt.Errorf("didn't get accounts: %v", err)
 	}
-	defer conn.Close(ctx)
-
-	// Format dates for SQL query (start and end of the target day)
-	startOfDay := targetDate.Format("2006-01-02 00:00:00")
-	endOfDay := targetDate.AddDate(0, 0, 1).Format("2006-01-02 00:00:00")
-
-	rows, err := conn.Query(ctx, 
-		"SELECT tweet_id, tweet_text, username, created_at FROM tweets0x001 WHERE created_at >= $1 AND created_at < $2 ORDER BY created_at DESC", 
-		startOfDay, endOfDay)
-	if err != nil {
-		return []Tweet{}, fmt.Errorf("failed to query tweets for date %s: %v", targetDate.Format("2006-01-02"), err)
+	if len(accounts) == 0 {
+		return []Tweet{}, nil
 	}
-	defer rows.Close()
 
-	validAccounts, err := getAccounts(accountsList)
+	tweets, err := a.store.QueryTweets(ctx, dayQuery(accountHandles(accounts), targetDate))
 	if err != nil {
-		return []Tweet{}, fmt.Errorf("didn't get accounts: %v", err)
-	}
-
-	var tweets []Tweet
-	for rows.Next() {
-		var tweet Tweet
-		var date time.Time
-		err := rows.Scan(&tweet.ID, &tweet.Text, &tweet.Username, &date)
-		tweet.CreatedAt = date.Format("2006-01-02 15:04:05")
-		if err != nil {
-			return []Tweet{}, fmt.Errorf("failed to scan row: %v", err)
-		}
-		if slices.Contains(validAccounts, tweet.Username) {
-			tweets = append(tweets, tweet)
-		}
+		return []Tweet{}, fmt.Errorf("failed to query tweets for date %s: %v", targetDate.Format("2006-01-02"), err)
 	}
 
 	return tweets, nil
 }
 
-// summarizeTweetsForAccount creates an LLM-based summary for a specific account's tweets
-func summarizeTweetsForAccount(tweets []Tweet, account string, date string) string {
-	if len(tweets) == 0 {
-		return fmt.Sprintf("No tweets found for @%s on %s.", account, date)
+// summarizeTweetsForAccount creates an LLM-based summary for a specific account's tweets,
+// and the claims it makes with the tweets they cite. withheld are the lines that stand in
+// for quarantined tweets.
+// If the LLM fails, it returns a plain count of the tweets along with the error.
+func (a *App) summarizeTweetsForAccount(ctx context.Context, tweets []Tweet, withheld []string, account string, date string) (string, []Claim, error) {
+	total := len(tweets) + len(withheld)
+	if total == 0 {
+		return fmt.Sprintf("No tweets found for @%s on %s.", account, date), nil, nil
 	}
 
-	// Load OpenAI API key
-	if err := godotenv.Load(".env"); err != nil {
-		return fmt.Sprintf("Error loading environment: %v", err)
+	if a.llm == nil {
+		// Fallback to simple summary if no OpenAI key; NewApp warned about it
+		return fmt.Sprintf("@%s posted %d tweets on %s. OpenAI API key not configured for detailed analysis.", account, total, date), nil, nil
 	}
-	
-	openaiToken := os.Getenv("OPENAI_API_KEY")
-	if openaiToken == "" {
-		// Fallback to simple summary if no OpenAI key
-		fmt.Printf("Not getting OPENAI_API_KEY")
-		return fmt.Sprintf("@%s posted %d tweets on %s. OpenAI API key not configured for detailed analysis.", account, len(tweets), date)
+	if len(tweets) == 0 {
+		return fmt.Sprintf("@%s posted %d tweets on %s, all withheld from the summarizer as possible prompt injection.", account, total, date), nil, nil
 	}
 
 	// Combine all tweets into a single text for analysis
 	var tweetTexts []string
 	for _, tweet := range tweets {
-		tweetTexts = append(tweetTexts, fmt.Sprintf("- %s", tweet.Text))
+		tweetTexts = append(tweetTexts, formatCitedLine([]string{tweet.ID}, tweet.Text))
 	}
+	tweetTexts = append(tweetTexts, withheld...)
 	
-	combinedText := fmt.Sprintf("Twitter activity for @%s on %s (%d tweets):\n\n%s", 
-		account, date, len(tweets), strings.Join(tweetTexts, "\n"))
+	header := fmt.Sprintf("Twitter activity for @%s on %s (%d tweets):", account, date, total)
 
-	fmt.Printf("Generating summary for @%s on %s (%d tweets)...\n", account, date, len(tweets))
+	fmt.Printf("Generating summary for @%s on %s (%d tweets)...\n", account, date, total)
 
-	// Use LLM to summarize the tweets
-	summary, err := Summarize(combinedText, openaiToken)
+	// Use LLM to summarize the tweets, in parts if they don't fit in the model's context
+	summary, claimBoxes, err := summarizeMapReduce(ctx, a.llm, a.model, header, tweetTexts)
 	if err != nil {
 		// Fallback to simple summary if LLM fails
-		return fmt.Sprintf("@%s posted %d tweets on %s. LLM analysis failed: %v", account, len(tweets), date, err)
+		return fmt.Sprintf("@%s posted %d tweets on %s.", account, total, date), nil, err
+	}
+	claims := validateClaims(claimBoxes, tweets)
+	for _, claim := range claims {
+		if len(claim.InvalidTweetIDs) > 0 {
+			fmt.Printf("Warning: summary of @%s on %s cites unknown tweets %v\n", account, date, claim.InvalidTweetIDs)
+		}
 	}
 
 	fmt.Printf("✓ Summary generated for @%s on %s\n", account, date)
 	fmt.Printf("--- Summary for @%s ---\n%s\n--- End Summary ---\n\n", account, summary)
 
-	return fmt.Sprintf("@%s Activity Summary for %s:\n\n%s", account, date, summary)
+	return fmt.Sprintf("@%s Activity Summary for %s:\n\n%s", account, date, summary), claims, nil
 }
 
-// summarizeTweets creates an LLM-based summary of tweets for a given day
-func summarizeTweets(tweets []Tweet, date string) string {
+// summarizeTweets creates an LLM-based summary of tweets for a given day.
+// If the LLM fails, it returns a plain count of the tweets along with the error.
+func (a *App) summarizeTweets(ctx context.Context, tweets []Tweet, date string) (string, error) {
 	if len(tweets) == 0 {
-		return "No tweets found for this day."
+		return "No tweets found for this day.", nil
 	}
 
-	// Load OpenAI API key
-	if err := godotenv.Load(".env"); err != nil {
-		return fmt.Sprintf("Error loading environment: %v", err)
-	}
-	
-	openaiToken := os.Getenv("OPENAI_API_KEY")
-	if openaiToken == "" {
+	if a.llm == nil {
 		// Fallback to simple summary if no OpenAI key
-		return fmt.Sprintf("Found %d tweets on %s. OpenAI API key not configured for detailed analysis.", len(tweets), date)
+		return fmt.Sprintf("Found %d tweets on %s. OpenAI API key not configured for detailed analysis.", len(tweets), date), nil
 	}
 
 	// Group tweets by account
@@ -153,179 +149,351 @@ func summarizeTweets(tweets []Tweet, date string) string {
 	}
 
 	// Create a comprehensive text for LLM analysis
-	var analysisText strings.Builder
-	analysisText.WriteString(fmt.Sprintf("Twitter activity analysis for %s:\n\n", date))
-	
-	for account, userTweets := range accountTweets {
-		analysisText.WriteString(fmt.Sprintf("@%s (%d tweets):\n", account, len(userTweets)))
+	header := fmt.Sprintf("Twitter activity analysis for %s:", date)
+	accounts := make([]string, 0, len(accountTweets))
+	for account := range accountTweets {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	var lines []string
+	for _, account := range accounts {
+		userTweets := accountTweets[account]
+		lines = append(lines, fmt.Sprintf("@%s (%d tweets):", account, len(userTweets)))
 		for _, tweet := range userTweets {
-			analysisText.WriteString(fmt.Sprintf("- %s\n", tweet.Text))
+			lines = append(lines, formatCitedLine([]string{tweet.ID}, tweet.Text))
 		}
-		analysisText.WriteString("\n")
+		lines = append(lines, "")
 	}
 
 	// Use LLM to analyze and summarize all the day's activity
-	summary, err := Summarize(analysisText.String(), openaiToken)
+	summary, _, err := summarizeMapReduce(ctx, a.llm, a.model, header, lines)
 	if err != nil {
 		// Fallback to simple summary if LLM fails
-		return fmt.Sprintf("Daily Activity Summary for %s:\nFound %d tweets from %d accounts. LLM analysis failed: %v", 
-			date, len(tweets), len(accountTweets), err)
+		return fmt.Sprintf("Daily Activity Summary for %s:\nFound %d tweets from %d accounts.", 
+			date, len(tweets), len(accountTweets)), err
 	}
 
-	return fmt.Sprintf("Daily Activity Summary for %s:\n\n%s", date, summary)
+	return fmt.Sprintf("Daily Activity Summary for %s:\n\n%s", date, summary), nil
 }
 
-// generateDailyReport creates a report for a specific day with separate account sections
-func (a *App) generateDailyReport(accountsList string, targetDate time.Time) (DailyReport, error) {
-	tweets, err := a.loadTweetsForDay(accountsList, targetDate)
+// generateDailyReport creates a report for a specific day with separate account sections.
+// Accounts are summarized in parallel, and reported in alphabetical order.
+func (a *App) generateDailyReport(ctx context.Context, accountsList string, targetDate time.Time) (DailyReport, error) {
+	tweets, err := a.loadTweetsForDay(ctx, accountsList, targetDate)
 	if err != nil {
 		return DailyReport{}, fmt.Errorf("failed to load tweets for %s: %v", targetDate.Format("2006-01-02"), err)
 	}
 
 	fmt.Printf("Loaded %d tweets for %s\n", len(tweets), targetDate.Format("2006-01-02"))
 
+	// Raw payloads stay in the store; they'd only bloat the saved reports
+	for i := range tweets {
+		tweets[i].Raw = nil
+	}
+
 	// Group tweets by account
 	accountTweets := make(map[string][]Tweet)
 	for _, tweet := range tweets {
 		accountTweets[tweet.Username] = append(accountTweets[tweet.Username], tweet)
 	}
+	accounts := make([]string, 0, len(accountTweets))
+	for account := range accountTweets {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
 	
 	fmt.Printf("Found activity from %d accounts on %s\n", len(accountTweets), targetDate.Format("2006-01-02"))
 	
-	var accountReports []AccountReport
-	for account, userTweets := range accountTweets {
+	accountReports := make([]AccountReport, len(accounts))
+	flaggedTweets := make([][]FlaggedTweet, len(accounts))
+	err = runPool(ctx, a.workers, len(accounts), func(ctx context.Context, i int) error {
+		account, userTweets := accounts[i], accountTweets[accounts[i]]
+		ctx = withLLMStage(ctx, llmStage{Stage: "account_summary", Account: account, Date: targetDate.Format("2006-01-02")})
 		fmt.Printf("Processing account @%s (%d tweets)...\n", account, len(userTweets))
-		summary := summarizeTweetsForAccount(userTweets, account, targetDate.Format("2006-01-02"))
+		promptTweets, flagged := a.screenTweets(userTweets, targetDate.Format("2006-01-02"))
+		for _, f := range flagged {
+			fmt.Printf("Warning: tweet %s of @%s looks like prompt injection (%s), quarantined: %v\n", f.TweetID, account, strings.Join(f.Patterns, ", "), f.Quarantined)
+		}
+		flaggedTweets[i] = flagged
+		summary, claims, err := a.summarizeTweetsForAccount(ctx, promptTweets, quarantinedLines(flagged), account, targetDate.Format("2006-01-02"))
+		if isRunFatal(err) {
+			return err
+		}
+		if err == nil && a.verify != verifyOff {
+			verifyCtx := withLLMStage(ctx, llmStage{Stage: "verification", Account: account, Date: targetDate.Format("2006-01-02")})
+			verifyErr := a.verifyClaims(verifyCtx, claims, promptTweets)
+			if isRunFatal(verifyErr) {
+				return verifyErr
+			}
+			if verifyErr != nil {
+				fmt.Printf("Warning: LLM verification failed for @%s on %s, keeping the lexical check: %v\n", account, targetDate.Format("2006-01-02"), verifyErr)
+			}
+		}
 		
-		accountReport := AccountReport{
+		accountReports[i] = AccountReport{
 			Username:   account,
 			TweetCount: len(userTweets),
 			Summary:    summary,
+			Claims:     claims,
 			Tweets:     userTweets,
 		}
-		accountReports = append(accountReports, accountReport)
+		for _, claim := range claims {
+			if claim.Status == claimUnsupported {
+				accountReports[i].UnsupportedClaims++
+			}
+		}
+		if err != nil {
+			fmt.Printf("Warning: LLM failed for @%s on %s: %v\n", account, targetDate.Format("2006-01-02"), err)
+			accountReports[i].Degraded = true
+			accountReports[i].Error = err.Error()
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		return DailyReport{}, err
+	}
+
+	var flagged []FlaggedTweet
+	for _, accountFlagged := range flaggedTweets {
+		flagged = append(flagged, accountFlagged...)
 	}
 
 	return DailyReport{
 		Date:           targetDate.Format("2006-01-02"),
+		Timezone:       targetDate.Location().String(),
 		TotalTweets:    len(tweets),
 		AccountReports: accountReports,
+		FlaggedTweets:  flagged,
 	}, nil
 }
 
-// generateWeeklyReport creates a report spanning multiple days with disaggregated account data
-func (a *App) generateWeeklyReport(accountsList string, startDate time.Time, days int) (WeeklyReport, error) {
+// dailyReport returns the stored report for targetDate if there is one, and otherwise generates
+// it. Reports of days that are over are stored, unless the LLM was missing or failed for them,
+// and only reused by runs with the same LLM provider, model, timezone and fingerprint.
+func (a *App) dailyReport(ctx context.Context, accountsList string, targetDate time.Time) (DailyReport, error) {
+	date := targetDate.Format("2006-01-02")
+	generator := ""
+	if a.llm != nil {
+		generator = a.llm.Name() + "/" + a.model
+	}
+	accounts, err := a.selectAccounts(accountsList, targetDate)
+	if err != nil {
+		return DailyReport{}, fmt.Errorf("didn't get accounts: %v", err)
+	}
+	fingerprint := a.reportFingerprint(accounts)
+	// Stored reports cover the whole list, so filtered runs neither reuse nor store them
+	cacheable := a.accountFilter.IsEmpty()
+	if !a.refresh && cacheable {
+		report, err := a.reports.LoadDailyReport(ctx, accountsList, targetDate)
+		if err == nil && report.Generator == generator && report.Timezone == targetDate.Location().String() && report.Fingerprint == fingerprint {
+			fmt.Printf("Reusing stored report for %s\n", date)
+			return report, nil
+		}
+		if err == nil && report.Fingerprint != fingerprint {
+			fmt.Printf("Stored report for %s was made for other accounts or with other options, generating it again\n", date)
+		} else if err == nil {
+			fmt.Printf("Stored report for %s was made by %s in %s, generating it again\n", date, report.Generator, report.Timezone)
+		} else if !errors.Is(err, ErrReportNotFound) {
+			fmt.Printf("Warning: failed to load stored report for %s, generating it again: %v\n", date, err)
+		}
+	}
+
+	report, err := a.generateDailyReport(ctx, accountsList, targetDate)
+	if err != nil {
+		return DailyReport{}, err
+	}
+	if !cacheable || a.llm == nil || report.TotalTweets == 0 || !dayIsOver(targetDate, time.Now()) {
+		return report, nil
+	}
+	for _, accountReport := range report.AccountReports {
+		if accountReport.Degraded {
+			return report, nil
+		}
+	}
+	report.GeneratedAt = time.Now().UTC().Format(time.RFC3339)
+	report.Generator = generator
+	report.Fingerprint = fingerprint
+	if err := a.reports.SaveDailyReport(ctx, accountsList, report); err != nil {
+		fmt.Printf("Warning: failed to store report for %s: %v\n", date, err)
+	}
+	return report, nil
+}
+
+// generateWeeklyReport creates a report spanning multiple days with disaggregated account data.
+// Days are generated in parallel, or reused from the report store, and reported in date order.
+func (a *App) generateWeeklyReport(ctx context.Context, accountsList string, startDate time.Time, days int) (WeeklyReport, error) {
 	var dailyReports []DailyReport
 	totalTweets := 0
 
 	fmt.Printf("Generating %d-day report starting from %s...\n", days, startDate.Format("2006-01-02"))
 
-	for i := 0; i < days; i++ {
+	generated := make([]*DailyReport, days)
+	err := runPool(ctx, a.workers, days, func(ctx context.Context, i int) error {
 		currentDate := startDate.AddDate(0, 0, i)
 		fmt.Printf("Processing day %d/%d: %s\n", i+1, days, currentDate.Format("2006-01-02"))
 		
-		dailyReport, err := a.generateDailyReport(accountsList, currentDate)
+		dailyReport, err := a.dailyReport(ctx, accountsList, currentDate)
 		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if isRunFatal(err) {
+				return err
+			}
 			fmt.Printf("Warning: Failed to generate report for %s: %v\n", currentDate.Format("2006-01-02"), err)
+			return nil
+		}
+		generated[i] = &dailyReport
+		return nil
+	})
+	if err != nil {
+		return WeeklyReport{}, err
+	}
+
+	var degraded []DegradedSection
+	var flagged []FlaggedTweet
+	for _, dailyReport := range generated {
+		if dailyReport == nil {
 			continue
 		}
-		
-		dailyReports = append(dailyReports, dailyReport)
+		dailyReports = append(dailyReports, *dailyReport)
 		totalTweets += dailyReport.TotalTweets
+		flagged = append(flagged, dailyReport.FlaggedTweets...)
+		for _, accountReport := range dailyReport.AccountReports {
+			if accountReport.Degraded {
+				degraded = append(degraded, DegradedSection{
+					Section: "account",
+					Date:    dailyReport.Date,
+					Account: accountReport.Username,
+					Error:   accountReport.Error,
+				})
+			}
+		}
 	}
 
 	// Generate overall summary
-	overallSummary := generateOverallSummary(dailyReports)
+	overallSummary, err := a.generateOverallSummary(withLLMStage(ctx, llmStage{Stage: "overall_summary"}), dailyReports)
+	if ctx.Err() != nil {
+		return WeeklyReport{}, ctx.Err()
+	}
+	if isRunFatal(err) {
+		return WeeklyReport{}, err
+	}
+	if err != nil {
+		degraded = append(degraded, DegradedSection{Section: "overall_summary", Error: err.Error()})
+	}
 	
+	var groups []AccountGroup
+	if a.groupBy != "" {
+		accounts, err := loadAccounts(accountsList)
+		if err != nil {
+			return WeeklyReport{}, fmt.Errorf("didn't get accounts: %v", err)
+		}
+		groups = groupAccounts(a.groupBy, accounts, dailyReports)
+	}
+
+	alerts, err := a.detectAnomalies(ctx, accountsList, startDate, days)
+	if ctx.Err() != nil {
+		return WeeklyReport{}, ctx.Err()
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to detect anomalies: %v\n", err)
+	}
+
+	classifications, err := a.classifyList(ctx, accountsList, startDate, days)
+	if ctx.Err() != nil {
+		return WeeklyReport{}, ctx.Err()
+	}
+	if err != nil {
+		fmt.Printf("Warning: failed to classify accounts: %v\n", err)
+	}
+
 	endDate := startDate.AddDate(0, 0, days-1)
 
 	return WeeklyReport{
-		StartDate:      startDate.Format("2006-01-02"),
-		EndDate:        endDate.Format("2006-01-02"),
-		DailyReports:   dailyReports,
-		OverallSummary: overallSummary,
-		TotalTweets:    totalTweets,
+		StartDate:            startDate.Format("2006-01-02"),
+		EndDate:              endDate.Format("2006-01-02"),
+		Timezone:             startDate.Location().String(),
+		DailyReports:         dailyReports,
+		OverallSummary:       overallSummary,
+		TotalTweets:          totalTweets,
+		DegradedSections:     degraded,
+		FlaggedTweets:        flagged,
+		Cost:                 a.costReport(),
+		AccountFilter:        a.accountFilter.String(),
+		GroupBy:              a.groupBy,
+		Groups:               groups,
+		Alerts:               alerts,
+		Classifier:           a.classifier.Description,
+		ClassifierCalibrated: a.classifier.calibrated(),
+		Classifications:      classifications,
 	}, nil
 }
 
-// generateOverallSummary creates an LLM-based summary across multiple daily reports
-func generateOverallSummary(dailyReports []DailyReport) string {
+// generateOverallSummary creates an LLM-based summary across multiple daily reports.
+// If the LLM fails, it returns the simple summary along with the error.
+func (a *App) generateOverallSummary(ctx context.Context, dailyReports []DailyReport) (string, error) {
 	if len(dailyReports) == 0 {
-		return "No daily reports available for summary."
+		return "No daily reports available for summary.", nil
 	}
 
 	fmt.Printf("Generating overall summary for %d days of reports...\n", len(dailyReports))
 
-	// Load OpenAI API key
-	if err := godotenv.Load(".env"); err != nil {
-		return fmt.Sprintf("Error loading environment: %v", err)
-	}
-	
-	openaiToken := os.Getenv("OPENAI_API_KEY")
-	if openaiToken == "" {
+	if a.llm == nil {
 		// Fallback to existing simple summary logic
 		fmt.Printf("No OpenAI API key found, using simple summary...\n")
-		return generateSimpleOverallSummary(dailyReports)
+		return generateSimpleOverallSummary(dailyReports), nil
 	}
 
-	// Prepare comprehensive data for LLM analysis
-	var analysisText strings.Builder
-	analysisText.WriteString("MULTI-DAY TWITTER ACTIVITY ANALYSIS\n")
-	analysisText.WriteString("=====================================\n\n")
-
 	// Aggregate statistics
 	totalTweets := 0
 	allAccounts := make(map[string]int)
-	activeDays := make(map[string]int)
-
 	for _, report := range dailyReports {
 		totalTweets += report.TotalTweets
-		analysisText.WriteString(fmt.Sprintf("Day %s (%d total tweets):\n", report.Date, report.TotalTweets))
-		
 		for _, accountReport := range report.AccountReports {
 			allAccounts[accountReport.Username] += accountReport.TweetCount
-			activeDays[accountReport.Username]++
-			
-			analysisText.WriteString(fmt.Sprintf("  @%s: %d tweets\n", accountReport.Username, accountReport.TweetCount))
-			
-			// Include some sample tweets for context
-			if len(accountReport.Tweets) > 0 {
-				analysisText.WriteString("    Sample tweets:\n")
-				sampleCount := min(3, len(accountReport.Tweets)) // Show up to 3 sample tweets
-				for i := 0; i < sampleCount; i++ {
-					tweet := accountReport.Tweets[i].Text
-					if len(tweet) > 100 {
-						tweet = tweet[:100] + "..."
-					}
-					analysisText.WriteString(fmt.Sprintf("    - %s\n", tweet))
-				}
-			}
 		}
-		analysisText.WriteString("\n")
 	}
 
-	analysisText.WriteString(fmt.Sprintf("\nPeriod: %s to %s (%d days)\n", 
+	var header strings.Builder
+	header.WriteString("MULTI-DAY TWITTER ACTIVITY ANALYSIS\n")
+	header.WriteString("=====================================\n\n")
+	header.WriteString(fmt.Sprintf("Period: %s to %s (%d days)\n", 
 		dailyReports[0].Date, 
 		dailyReports[len(dailyReports)-1].Date, 
 		len(dailyReports)))
-	analysisText.WriteString(fmt.Sprintf("Total tweets: %d\n", totalTweets))
-	analysisText.WriteString(fmt.Sprintf("Unique accounts: %d\n\n", len(allAccounts)))
+	header.WriteString(fmt.Sprintf("Total tweets: %d\n", totalTweets))
+	header.WriteString(fmt.Sprintf("Unique accounts: %d\n\n", len(allAccounts)))
+	header.WriteString("Below is each day's activity, with a summary of each account's tweets that day as a JSON string:")
+
+	// Each account's daily summary already condenses all of its tweets, so the
+	// overall summary is built from those rather than from samples of raw tweets.
+	// Summaries can repeat what the tweets said, so they are quoted like tweets, and
+	// Summarize delimits them as data.
+	var lines []string
+	for _, report := range dailyReports {
+		lines = append(lines, fmt.Sprintf("Day %s (%d total tweets):", report.Date, report.TotalTweets))
+		for _, accountReport := range report.AccountReports {
+			lines = append(lines, fmt.Sprintf("  @%s: %d tweets. %s", accountReport.Username, accountReport.TweetCount, quoteData(accountReport.Summary)))
+		}
+		lines = append(lines, "")
+	}
 
-	fmt.Printf("Sending %d characters to LLM for overall summary...\n", len(analysisText.String()))
+	fmt.Printf("Sending %d days of account summaries to LLM for overall summary...\n", len(dailyReports))
 
-	// Use LLM to create comprehensive summary
-	summary, err := Summarize(analysisText.String(), openaiToken)
+	// Use LLM to create comprehensive summary, in parts if the period is long
+	summary, _, err := summarizeMapReduce(ctx, a.llm, a.model, header.String(), lines)
 	if err != nil {
 		// Fallback to simple summary if LLM fails
 		fmt.Printf("LLM analysis failed: %v\nFalling back to simple summary...\n", err)
-		return fmt.Sprintf("MULTI-DAY ACTIVITY SUMMARY\n==========================\n\nLLM analysis failed: %v\n\nFalling back to simple summary:\n\n%s", 
-			err, generateSimpleOverallSummary(dailyReports))
+		return generateSimpleOverallSummary(dailyReports), err
 	}
 
 	fmt.Printf("✓ Overall summary generated successfully\n")
 	fmt.Printf("--- Overall Summary ---\n%s\n--- End Overall Summary ---\n\n", summary)
 
-	return summary
+	return summary, nil
 }
 
 // generateSimpleOverallSummary creates a basic summary without LLM (fallback)
@@ -438,9 +606,8 @@ func min(a, b int) int {
 	return b
 }
 
-// saveReportToFile saves a report to a JSON file
-func saveReportToFile(report interface{}, filename string) error {
-	reportsDir := "./data/reports"
+// saveReportToFile saves a report to a JSON file in reportsDir
+func saveReportToFile(report interface{}, reportsDir string, filename string) error {
 	if err := os.MkdirAll(reportsDir, 0755); err != nil {
 		return fmt.Errorf("failed to create reports directory: %v", err)
 	}
@@ -459,36 +626,58 @@ func saveReportToFile(report interface{}, filename string) error {
 	return nil
 }
 
-// GenerateReports is the main function to generate reports
-func (a *App) GenerateReports(accountsList string, startDate time.Time, days int) error {
+// GenerateReports is the main function to generate reports. Cancelling ctx stops the
+// LLM calls in flight, and no report is saved.
+func (a *App) GenerateReports(ctx context.Context, accountsList string, startDate time.Time, days int) error {
 	fmt.Printf("Starting report generation for %s accounts...\n", accountsList)
+	a.resetUsage()
 	
 	// Generate the multi-day report
-	weeklyReport, err := a.generateWeeklyReport(accountsList, startDate, days)
+	weeklyReport, err := a.generateWeeklyReport(ctx, accountsList, startDate, days)
 	if err != nil {
-		return fmt.Errorf("failed to generate weekly report: %v", err)
+		return fmt.Errorf("failed to generate weekly report: %w", err)
 	}
 
 	// Save the full report
 	reportFilename := fmt.Sprintf("report_%s_%s_to_%s.json", 
-		accountsList, 
+		a.reportName(accountsList), 
 		weeklyReport.StartDate, 
 		weeklyReport.EndDate)
 	
-	if err := saveReportToFile(weeklyReport, reportFilename); err != nil {
-		return fmt.Errorf("failed to save report: %v", err)
+	for _, format := range a.formats {
+		switch format {
+		case "json":
+			err = saveReportToFile(weeklyReport, a.outputDir, reportFilename)
+		case "md":
+			err = saveRenderedReport(weeklyReport, accountsList, renderMarkdown, a.outputDir, strings.TrimSuffix(reportFilename, ".json")+".md")
+		case "html":
+			err = saveRenderedReport(weeklyReport, accountsList, renderHTML, a.outputDir, strings.TrimSuffix(reportFilename, ".json")+".html")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save report: %v", err)
+		}
 	}
 
 	// Print summary to console
 	fmt.Println("\n" + weeklyReport.OverallSummary)
+	if len(weeklyReport.Alerts) > 0 {
+		fmt.Printf("\n%d alerts:\n", len(weeklyReport.Alerts))
+		for _, alert := range weeklyReport.Alerts {
+			fmt.Printf("  %s\n", alertLine(alert))
+		}
+		a.notifyAlerts(ctx, weeklyReport.Alerts)
+	}
+	if weeklyReport.Cost != nil {
+		fmt.Println("\n" + weeklyReport.Cost.String())
+	}
 	
 	// Save a text summary as well
 	summaryFilename := fmt.Sprintf("summary_%s_%s_to_%s.txt", 
-		accountsList, 
+		a.reportName(accountsList), 
 		weeklyReport.StartDate, 
 		weeklyReport.EndDate)
 	
-	summaryPath := filepath.Join("./reports", summaryFilename)
+	summaryPath := filepath.Join(a.outputDir, summaryFilename)
 	if err := os.WriteFile(summaryPath, []byte(weeklyReport.OverallSummary), 0644); err != nil {
 		fmt.Printf("Warning: Failed to save text summary: %v\n", err)
 	} else {
@@ -497,3 +686,24 @@ func (a *App) GenerateReports(accountsList string, startDate time.Time, days int
 
 	return nil
 }
+
+// GenerateDailyReportFile generates the report for a single day and saves it
+func (a *App) GenerateDailyReportFile(ctx context.Context, accountsList string, targetDate time.Time) error {
+	fmt.Printf("Starting daily report generation for %s accounts on %s...\n", accountsList, targetDate.Format("2006-01-02"))
+	a.resetUsage()
+
+	dailyReport, err := a.dailyReport(ctx, accountsList, targetDate)
+	if err != nil {
+		return fmt.Errorf("failed to generate daily report: %w", err)
+	}
+
+	reportFilename := fmt.Sprintf("daily_%s_%s.json", a.reportName(accountsList), dailyReport.Date)
+	if err := saveReportToFile(dailyReport, a.outputDir, reportFilename); err != nil {
+		return fmt.Errorf("failed to save report: %v", err)
+	}
+	if cost := a.costReport(); cost != nil {
+		fmt.Println(cost.String())
+	}
+
+	return nil
+}
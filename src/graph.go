@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of interactions between accounts
+const (
+	interactionMention = "mention"
+	interactionReply   = "reply"
+	interactionQuote   = "quote"
+	interactionRetweet = "retweet"
+)
+
+var interactionKinds = []string{interactionMention, interactionReply, interactionQuote, interactionRetweet}
+
+// Interaction is a directed, weighted edge: how often Source interacted with Target in a way
+type Interaction struct {
+	Source string `json:"source"` // lowercase handles
+	Target string `json:"target"`
+	Kind   string `json:"kind"`
+	Weight int    `json:"weight"`
+}
+
+// DailyInteractions are the interactions of the accounts of a list on a day
+type DailyInteractions struct {
+	Date        string        `json:"date"`
+	Timezone    string        `json:"timezone"`
+	Fingerprint string        `json:"fingerprint,omitempty"` // of the accounts, see interactionsFingerprint
+	Edges       []Interaction `json:"edges"`
+}
+
+var (
+	mentionPattern       = regexp.MustCompile(`(?:^|[^\w@])@(\w{1,15})\b`)
+	leadingMentions      = regexp.MustCompile(`^(\s*@\w{1,15})+\s*`)
+	retweetPrefixPattern = regexp.MustCompile(`^RT @(\w{1,15}):`)
+)
+
+// tweetInteractions returns who the tweet interacts with. A retweet only counts as a
+// retweet, since the mentions in its text are the original author's; the @handles a reply
+// starts with are who it replies to, so they don't count as mentions too.
+func tweetInteractions(tweet Tweet) []Interaction {
+	source := strings.ToLower(tweet.Username)
+	var interactions []Interaction
+	add := func(target string, kind string) {
+		target = strings.ToLower(target)
+		if target == "" || target == source {
+			return
+		}
+		interactions = append(interactions, Interaction{Source: source, Target: target, Kind: kind, Weight: 1})
+	}
+
+	retweeted := tweet.RetweetedUsername
+	if match := retweetPrefixPattern.FindStringSubmatch(tweet.Text); retweeted == "" && match != nil {
+		retweeted = match[1]
+	}
+	if retweeted != "" {
+		add(retweeted, interactionRetweet)
+		return interactions
+	}
+
+	text := tweet.Text
+	if tweet.InReplyToUsername != "" {
+		add(tweet.InReplyToUsername, interactionReply)
+		text = leadingMentions.ReplaceAllString(text, "")
+	}
+	if tweet.QuotedUsername != "" {
+		add(tweet.QuotedUsername, interactionQuote)
+	}
+	mentioned := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		if handle := strings.ToLower(match[1]); !mentioned[handle] {
+			mentioned[handle] = true
+			add(handle, interactionMention)
+		}
+	}
+	return interactions
+}
+
+// aggregateInteractions sums the weights of identical edges, in a stable order
+func aggregateInteractions(interactions []Interaction) []Interaction {
+	type key struct{ source, target, kind string }
+	weights := make(map[key]int)
+	for _, interaction := range interactions {
+		weights[key{interaction.Source, interaction.Target, interaction.Kind}] += interaction.Weight
+	}
+	edges := make([]Interaction, 0, len(weights))
+	for k, weight := range weights {
+		edges = append(edges, Interaction{Source: k.source, Target: k.target, Kind: k.kind, Weight: weight})
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Source != edges[j].Source {
+			return edges[i].Source < edges[j].Source
+		}
+		if edges[i].Target != edges[j].Target {
+			return edges[i].Target < edges[j].Target
+		}
+		return edges[i].Kind < edges[j].Kind
+	})
+	return edges
+}
+
+// dailyInteractions returns the stored interactions of the day if there are some for the
+// accounts the list has on the day, and otherwise extracts them from the day's tweets,
+// storing them once the day is over
+func (a *App) dailyInteractions(ctx context.Context, accountsList string, targetDate time.Time) (DailyInteractions, error) {
+	date := targetDate.Format(dateLayout)
+	accounts, err := a.selectAccounts(accountsList, targetDate)
+	if err != nil {
+		return DailyInteractions{}, fmt.Errorf("didn't get accounts: %v", err)
+	}
+	fingerprint := interactionsFingerprint(accounts)
+	cacheable := a.accountFilter.IsEmpty()
+	if !a.refresh && cacheable {
+		day, err := a.reports.LoadDailyInteractions(ctx, accountsList, targetDate)
+		if err == nil && day.Timezone == targetDate.Location().String() && day.Fingerprint == fingerprint {
+			return day, nil
+		}
+		if err == nil && day.Fingerprint != fingerprint {
+			fmt.Printf("Stored interactions for %s are of other accounts, extracting them again\n", date)
+		} else if err != nil && !errors.Is(err, ErrReportNotFound) {
+			fmt.Printf("Warning: failed to load stored interactions for %s, extracting them again: %v\n", date, err)
+		}
+	}
+
+	tweets, err := a.loadTweetsForDay(ctx, accountsList, targetDate)
+	if err != nil {
+		return DailyInteractions{}, err
+	}
+	var interactions []Interaction
+	for _, tweet := range tweets {
+		interactions = append(interactions, tweetInteractions(tweet)...)
+	}
+	day := DailyInteractions{
+		Date:        date,
+		Timezone:    targetDate.Location().String(),
+		Fingerprint: fingerprint,
+		Edges:       aggregateInteractions(interactions),
+	}
+
+	if cacheable && dayIsOver(targetDate, time.Now()) {
+		if err := a.reports.SaveDailyInteractions(ctx, accountsList, day); err != nil {
+			fmt.Printf("Warning: failed to store interactions for %s: %v\n", date, err)
+		}
+	}
+	return day, nil
+}
+
+// GraphNode is an account in the interaction graph. Observed accounts are in the list;
+// the others only appear as targets.
+type GraphNode struct {
+	ID          string  `json:"id"`
+	Label       string  `json:"label"`
+	Observed    bool    `json:"observed"`
+	InDegree    int     `json:"in_degree"`  // accounts that interacted with this one
+	OutDegree   int     `json:"out_degree"` // accounts this one interacted with
+	WeightedIn  int     `json:"weighted_in"`
+	WeightedOut int     `json:"weighted_out"`
+	Reciprocity float64 `json:"reciprocity"` // share of the accounts it interacted with that interacted back
+	Community   int     `json:"community"`
+}
+
+// GraphEdge is all the interactions from Source to Target over the window
+type GraphEdge struct {
+	Source string         `json:"source"`
+	Target string         `json:"target"`
+	Weight int            `json:"weight"`
+	Kinds  map[string]int `json:"kinds"` // weight by kind of interaction
+	Days   map[string]int `json:"days"`  // weight by day
+}
+
+// Graph is the interaction graph of a list over a window
+type Graph struct {
+	AccountsList string       `json:"accounts_list"`
+	StartDate    string       `json:"start_date"`
+	EndDate      string       `json:"end_date"`
+	Timezone     string       `json:"timezone"`
+	Days         []string     `json:"days"`
+	Nodes        []*GraphNode `json:"nodes"`
+	Edges        []*GraphEdge `json:"edges"`
+	Reciprocity  float64      `json:"reciprocity"` // share of edges whose reverse edge exists
+	Communities  int          `json:"communities"`
+	Change       *GraphChange `json:"change,omitempty"` // compared to the window before
+}
+
+// GraphChange is how the graph differs from the one of the previous window of the same length
+type GraphChange struct {
+	PreviousStart string       `json:"previous_start"`
+	PreviousEnd   string       `json:"previous_end"`
+	NewEdges      []EdgeChange `json:"new_edges,omitempty"`
+	GoneEdges     []EdgeChange `json:"gone_edges,omitempty"`
+	Changed       []EdgeChange `json:"changed_edges,omitempty"` // edges in both, whose weight changed
+}
+
+// EdgeChange is the weight of an edge in the previous and current windows
+type EdgeChange struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Previous int    `json:"previous"`
+	Current  int    `json:"current"`
+}
+
+// buildInteractionGraph builds the graph of the accounts of the list over days days from
+// startDate, and compares it to the graph of the days before
+func (a *App) buildInteractionGraph(ctx context.Context, accountsList string, startDate time.Time, days int, internal bool) (*Graph, error) {
+	accounts, err := a.selectAccounts(accountsList, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("didn't get accounts: %v", err)
+	}
+	labels := make(map[string]string)
+	for _, account := range accounts {
+		labels[strings.ToLower(account.Handle)] = account.Handle
+	}
+
+	window := func(start time.Time) (*Graph, error) {
+		graph := &Graph{
+			AccountsList: accountsList,
+			StartDate:    start.Format(dateLayout),
+			EndDate:      start.AddDate(0, 0, days-1).Format(dateLayout),
+			Timezone:     start.Location().String(),
+		}
+		var dailies []DailyInteractions
+		for i := 0; i < days; i++ {
+			day, err := a.dailyInteractions(ctx, accountsList, start.AddDate(0, 0, i))
+			if err != nil {
+				return nil, err
+			}
+			graph.Days = append(graph.Days, day.Date)
+			dailies = append(dailies, day)
+		}
+		graph.build(dailies, labels, internal)
+		return graph, nil
+	}
+
+	graph, err := window(startDate)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := window(startDate.AddDate(0, 0, -days))
+	if err != nil {
+		fmt.Printf("Warning: failed to build the graph of the previous window: %v\n", err)
+	} else {
+		graph.Change = compareGraphs(previous, graph)
+	}
+	return graph, nil
+}
+
+// build fills the graph from daily interactions. labels maps the observed accounts to their
+// handles; with internal, edges to accounts that aren't observed are left out.
+func (g *Graph) build(dailies []DailyInteractions, labels map[string]string, internal bool) {
+	nodes := make(map[string]*GraphNode)
+	node := func(id string) *GraphNode {
+		if n, ok := nodes[id]; ok {
+			return n
+		}
+		n := &GraphNode{ID: id, Label: id}
+		if label, ok := labels[id]; ok {
+			n.Label, n.Observed = label, true
+		}
+		nodes[id] = n
+		return n
+	}
+	for id := range labels {
+		node(id)
+	}
+
+	type pair struct{ source, target string }
+	edges := make(map[pair]*GraphEdge)
+	for _, day := range dailies {
+		for _, interaction := range day.Edges {
+			if _, observed := labels[interaction.Target]; internal && !observed {
+				continue
+			}
+			node(interaction.Source)
+			node(interaction.Target)
+			key := pair{interaction.Source, interaction.Target}
+			edge, ok := edges[key]
+			if !ok {
+				edge = &GraphEdge{Source: key.source, Target: key.target, Kinds: make(map[string]int), Days: make(map[string]int)}
+				edges[key] = edge
+			}
+			edge.Weight += interaction.Weight
+			edge.Kinds[interaction.Kind] += interaction.Weight
+			edge.Days[day.Date] += interaction.Weight
+		}
+	}
+
+	reciprocated := 0
+	for key, edge := range edges {
+		nodes[key.source].OutDegree++
+		nodes[key.source].WeightedOut += edge.Weight
+		nodes[key.target].InDegree++
+		nodes[key.target].WeightedIn += edge.Weight
+		if _, ok := edges[pair{key.target, key.source}]; ok {
+			reciprocated++
+			nodes[key.source].Reciprocity++
+		}
+	}
+	for _, n := range nodes {
+		if n.OutDegree > 0 {
+			n.Reciprocity /= float64(n.OutDegree)
+		}
+	}
+	if len(edges) > 0 {
+		g.Reciprocity = float64(reciprocated) / float64(len(edges))
+	}
+
+	g.Nodes = make([]*GraphNode, 0, len(nodes))
+	for _, n := range nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	g.Edges = make([]*GraphEdge, 0, len(edges))
+	for _, edge := range edges {
+		g.Edges = append(g.Edges, edge)
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].Weight != g.Edges[j].Weight {
+			return g.Edges[i].Weight > g.Edges[j].Weight
+		}
+		if g.Edges[i].Source != g.Edges[j].Source {
+			return g.Edges[i].Source < g.Edges[j].Source
+		}
+		return g.Edges[i].Target < g.Edges[j].Target
+	})
+	g.Communities = g.detectCommunities()
+}
+
+// maxLabelPropagationRounds bounds label propagation, which can oscillate on some graphs
+const maxLabelPropagationRounds = 100
+
+// detectCommunities labels nodes with communities by label propagation over the graph
+// taken as undirected and weighted: each node takes the label with the most weight among
+// its neighbours, until no label changes. Nodes are visited in ID order and ties go to
+// the smallest label, so the result is deterministic. It returns the number of communities,
+// which are numbered from 0 by their smallest node.
+func (g *Graph) detectCommunities() int {
+	index := make(map[string]int)
+	for i, n := range g.Nodes {
+		index[n.ID] = i
+	}
+	neighbours := make([]map[int]int, len(g.Nodes))
+	for i := range neighbours {
+		neighbours[i] = make(map[int]int)
+	}
+	for _, edge := range g.Edges {
+		s, t := index[edge.Source], index[edge.Target]
+		neighbours[s][t] += edge.Weight
+		neighbours[t][s] += edge.Weight
+	}
+
+	labels := make([]int, len(g.Nodes))
+	for i := range labels {
+		labels[i] = i
+	}
+	for round := 0; round < maxLabelPropagationRounds; round++ {
+		changed := false
+		for i := range g.Nodes {
+			if len(neighbours[i]) == 0 {
+				continue
+			}
+			weights := make(map[int]int)
+			for j, weight := range neighbours[i] {
+				weights[labels[j]] += weight
+			}
+			best, bestWeight := labels[i], weights[labels[i]]
+			for label, weight := range weights {
+				if weight > bestWeight || (weight == bestWeight && label < best) {
+					best, bestWeight = label, weight
+				}
+			}
+			if best != labels[i] {
+				labels[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	numbers := make(map[int]int)
+	for i, n := range g.Nodes {
+		number, ok := numbers[labels[i]]
+		if !ok {
+			number = len(numbers)
+			numbers[labels[i]] = number
+		}
+		n.Community = number
+	}
+	return len(numbers)
+}
+
+// compareGraphs lists the edges that appeared, disappeared or changed weight
+func compareGraphs(previous *Graph, current *Graph) *GraphChange {
+	change := &GraphChange{PreviousStart: previous.StartDate, PreviousEnd: previous.EndDate}
+	before := make(map[[2]string]int)
+	for _, edge := range previous.Edges {
+		before[[2]string{edge.Source, edge.Target}] = edge.Weight
+	}
+	for _, edge := range current.Edges {
+		key := [2]string{edge.Source, edge.Target}
+		weight, ok := before[key]
+		delete(before, key)
+		switch {
+		case !ok:
+			change.NewEdges = append(change.NewEdges, EdgeChange{Source: edge.Source, Target: edge.Target, Current: edge.Weight})
+		case weight != edge.Weight:
+			change.Changed = append(change.Changed, EdgeChange{Source: edge.Source, Target: edge.Target, Previous: weight, Current: edge.Weight})
+		}
+	}
+	for _, edge := range previous.Edges {
+		if weight, ok := before[[2]string{edge.Source, edge.Target}]; ok {
+			change.GoneEdges = append(change.GoneEdges, EdgeChange{Source: edge.Source, Target: edge.Target, Previous: weight})
+		}
+	}
+	return change
+}
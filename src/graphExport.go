@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// graphFormats are the formats an interaction graph can be saved in
+var graphFormats = []string{"json", "graphml", "gexf"}
+
+// GraphML, as read by Gephi, yEd, NetworkX and igraph
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLItem `xml:"node"`
+	Edges       []graphMLItem `xml:"edge"`
+}
+
+// graphMLItem is a node, or an edge when Source and Target are set
+type graphMLItem struct {
+	ID     string        `xml:"id,attr,omitempty"`
+	Source string        `xml:"source,attr,omitempty"`
+	Target string        `xml:"target,attr,omitempty"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func writeGraphML(w io.Writer, g *Graph) error {
+	doc := graphMLDocument{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{"label", "node", "label", "string"},
+			{"observed", "node", "observed", "boolean"},
+			{"community", "node", "community", "int"},
+			{"in_degree", "node", "in_degree", "int"},
+			{"out_degree", "node", "out_degree", "int"},
+			{"reciprocity", "node", "reciprocity", "double"},
+			{"weight", "edge", "weight", "int"},
+		},
+		Graph: graphMLGraph{ID: g.AccountsList, EdgeDefault: "directed"},
+	}
+	for _, kind := range interactionKinds {
+		doc.Keys = append(doc.Keys, graphMLKey{kind, "edge", kind, "int"})
+	}
+
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLItem{ID: n.ID, Data: []graphMLData{
+			{"label", n.Label},
+			{"observed", strconv.FormatBool(n.Observed)},
+			{"community", strconv.Itoa(n.Community)},
+			{"in_degree", strconv.Itoa(n.InDegree)},
+			{"out_degree", strconv.Itoa(n.OutDegree)},
+			{"reciprocity", strconv.FormatFloat(n.Reciprocity, 'f', 3, 64)},
+		}})
+	}
+	for _, edge := range g.Edges {
+		data := []graphMLData{{"weight", strconv.Itoa(edge.Weight)}}
+		for _, kind := range interactionKinds {
+			if weight := edge.Kinds[kind]; weight > 0 {
+				data = append(data, graphMLData{kind, strconv.Itoa(weight)})
+			}
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLItem{Source: edge.Source, Target: edge.Target, Data: data})
+	}
+	return writeXML(w, doc)
+}
+
+// GEXF 1.3, Gephi's format. The graph is dynamic: each edge has its weight on each day,
+// so Gephi's timeline shows how the graph changes.
+
+type gexfDocument struct {
+	XMLName xml.Name  `xml:"gexf"`
+	Xmlns   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Meta    gexfMeta  `xml:"meta"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfMeta struct {
+	LastModified string `xml:"lastmodifieddate,attr"`
+	Creator      string `xml:"creator"`
+	Description  string `xml:"description"`
+}
+
+type gexfGraph struct {
+	Mode            string           `xml:"mode,attr"`
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	TimeFormat      string           `xml:"timeformat,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Mode       string          `xml:"mode,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID        string         `xml:"id,attr"`
+	Label     string         `xml:"label,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID        string         `xml:"id,attr"`
+	Source    string         `xml:"source,attr"`
+	Target    string         `xml:"target,attr"`
+	Weight    int            `xml:"weight,attr"`
+	AttValues []gexfAttValue `xml:"attvalues>attvalue"`
+	Spells    []gexfSpell    `xml:"spells>spell"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+	Start string `xml:"start,attr,omitempty"`
+	End   string `xml:"end,attr,omitempty"`
+}
+
+type gexfSpell struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+func writeGEXF(w io.Writer, g *Graph) error {
+	doc := gexfDocument{
+		Xmlns:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Meta: gexfMeta{
+			LastModified: time.Now().UTC().Format(dateLayout),
+			Creator:      "twitter-cli",
+			Description:  fmt.Sprintf("Interactions of %s from %s to %s (%s)", g.AccountsList, g.StartDate, g.EndDate, g.Timezone),
+		},
+		Graph: gexfGraph{
+			Mode:            "dynamic",
+			DefaultEdgeType: "directed",
+			TimeFormat:      "date",
+			Attributes: []gexfAttributes{
+				{Class: "node", Mode: "static", Attributes: []gexfAttribute{
+					{"observed", "observed", "boolean"},
+					{"community", "community", "integer"},
+					{"in_degree", "in_degree", "integer"},
+					{"out_degree", "out_degree", "integer"},
+					{"reciprocity", "reciprocity", "double"},
+				}},
+				{Class: "edge", Mode: "dynamic", Attributes: []gexfAttribute{{"weight", "Weight", "integer"}}},
+			},
+		},
+	}
+
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{ID: n.ID, Label: n.Label, AttValues: []gexfAttValue{
+			{For: "observed", Value: strconv.FormatBool(n.Observed)},
+			{For: "community", Value: strconv.Itoa(n.Community)},
+			{For: "in_degree", Value: strconv.Itoa(n.InDegree)},
+			{For: "out_degree", Value: strconv.Itoa(n.OutDegree)},
+			{For: "reciprocity", Value: strconv.FormatFloat(n.Reciprocity, 'f', 3, 64)},
+		}})
+	}
+	for i, edge := range g.Edges {
+		e := gexfEdge{ID: strconv.Itoa(i), Source: edge.Source, Target: edge.Target, Weight: edge.Weight}
+		for _, day := range g.Days {
+			if weight := edge.Days[day]; weight > 0 {
+				e.AttValues = append(e.AttValues, gexfAttValue{For: "weight", Value: strconv.Itoa(weight), Start: day, End: day})
+				e.Spells = append(e.Spells, gexfSpell{Start: day, End: day})
+			}
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, e)
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeGraphJSON(w io.Writer, g *Graph) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(g)
+}
+
+// saveGraph writes the graph in format into dir, and returns the file's path
+func saveGraph(g *Graph, format string, dir string, name string) (string, error) {
+	write := map[string]func(io.Writer, *Graph) error{
+		"json":    writeGraphJSON,
+		"graphml": writeGraphML,
+		"gexf":    writeGEXF,
+	}[format]
+	if write == nil {
+		return "", fmt.Errorf("unknown graph format %q (want %s)", format, strings.Join(graphFormats, ", "))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create graph directory: %v", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("graph_%s_%s_to_%s.%s", name, g.StartDate, g.EndDate, format))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create graph file: %v", err)
+	}
+	if err := write(file, g); err != nil {
+		file.Close()
+		return "", fmt.Errorf("failed to write graph: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write graph file: %v", err)
+	}
+	return path, nil
+}
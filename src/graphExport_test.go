@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// exportTestGraph is a graph over two days, with edges of several kinds
+func exportTestGraph() *Graph {
+	g := &Graph{AccountsList: "test", StartDate: "2025-01-06", EndDate: "2025-01-07", Timezone: "UTC", Days: []string{"2025-01-06", "2025-01-07"}}
+	g.build([]DailyInteractions{
+		{Date: "2025-01-06", Edges: []Interaction{
+			{Source: "alice", Target: "bob", Kind: interactionReply, Weight: 2},
+			{Source: "alice", Target: "bob", Kind: interactionMention, Weight: 1},
+		}},
+		{Date: "2025-01-07", Edges: []Interaction{
+			{Source: "alice", Target: "bob", Kind: interactionReply, Weight: 1},
+			{Source: "bob", Target: "x_agent", Kind: interactionQuote, Weight: 1},
+		}},
+	}, map[string]string{"alice": "Alice", "bob": "bob"}, false)
+	return g
+}
+
+func TestWriteGraphML(t *testing.T) {
+	var out strings.Builder
+	if err := writeGraphML(&out, exportTestGraph()); err != nil {
+		t.Fatal(err)
+	}
+	var doc graphMLDocument
+	if err := xml.Unmarshal([]byte(out.String()), &doc); err != nil {
+		t.Fatalf("the GraphML doesn't parse: %v\n%s", err, out.String())
+	}
+
+	keys := make(map[string]graphMLKey)
+	for _, key := range doc.Keys {
+		keys[key.ID] = key
+	}
+	data := func(item graphMLItem) map[string]string {
+		values := make(map[string]string)
+		for _, d := range item.Data {
+			if _, ok := keys[d.Key]; !ok {
+				t.Errorf("data for undeclared key %q", d.Key)
+			}
+			values[d.Key] = d.Value
+		}
+		return values
+	}
+
+	if doc.Graph.EdgeDefault != "directed" || len(doc.Graph.Nodes) != 3 || len(doc.Graph.Edges) != 2 {
+		t.Fatalf("graph %+v, want 3 nodes and 2 directed edges", doc.Graph)
+	}
+	if alice := data(doc.Graph.Nodes[0]); doc.Graph.Nodes[0].ID != "alice" || alice["label"] != "Alice" || alice["observed"] != "true" || alice["out_degree"] != "1" {
+		t.Errorf("alice = %v", alice)
+	}
+	if x := data(doc.Graph.Nodes[2]); doc.Graph.Nodes[2].ID != "x_agent" || x["observed"] != "false" || x["in_degree"] != "1" {
+		t.Errorf("x_agent = %v", x)
+	}
+	edge := doc.Graph.Edges[0]
+	if values := data(edge); edge.Source != "alice" || edge.Target != "bob" || values["weight"] != "4" || values["reply"] != "3" || values["mention"] != "1" || values["quote"] != "" {
+		t.Errorf("alice to bob: %s to %s, %v", edge.Source, edge.Target, values)
+	}
+}
+
+func TestWriteGEXF(t *testing.T) {
+	var out strings.Builder
+	if err := writeGEXF(&out, exportTestGraph()); err != nil {
+		t.Fatal(err)
+	}
+	var doc gexfDocument
+	if err := xml.Unmarshal([]byte(out.String()), &doc); err != nil {
+		t.Fatalf("the GEXF doesn't parse: %v\n%s", err, out.String())
+	}
+
+	if doc.Version != "1.3" || doc.Graph.Mode != "dynamic" || doc.Graph.TimeFormat != "date" {
+		t.Errorf("graph %s %s %s, want a dynamic GEXF 1.3 graph over dates", doc.Version, doc.Graph.Mode, doc.Graph.TimeFormat)
+	}
+	if len(doc.Graph.Nodes) != 3 || doc.Graph.Nodes[0].Label != "Alice" {
+		t.Errorf("nodes = %+v, want alice, bob and x_agent", doc.Graph.Nodes)
+	}
+	if len(doc.Graph.Edges) != 2 {
+		t.Fatalf("edges = %+v, want 2", doc.Graph.Edges)
+	}
+	edge := doc.Graph.Edges[0]
+	if edge.Source != "alice" || edge.Target != "bob" || edge.Weight != 4 {
+		t.Errorf("edge = %+v, want alice to bob of weight 4", edge)
+	}
+	// The edge's weight on each day it was seen
+	want := []gexfAttValue{
+		{For: "weight", Value: "3", Start: "2025-01-06", End: "2025-01-06"},
+		{For: "weight", Value: "1", Start: "2025-01-07", End: "2025-01-07"},
+	}
+	if len(edge.AttValues) != len(want) || len(edge.Spells) != len(want) {
+		t.Fatalf("alice to bob has %d weights and %d spells, want %d", len(edge.AttValues), len(edge.Spells), len(want))
+	}
+	for i := range want {
+		if edge.AttValues[i] != want[i] || edge.Spells[i] != (gexfSpell{Start: want[i].Start, End: want[i].End}) {
+			t.Errorf("day %d: weight %+v and spell %+v, want %+v", i+1, edge.AttValues[i], edge.Spells[i], want[i])
+		}
+	}
+	if spells := doc.Graph.Edges[1].Spells; len(spells) != 1 || spells[0].Start != "2025-01-07" {
+		t.Errorf("bob to x_agent spells = %+v, want only the 7th", spells)
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTweetInteractions(t *testing.T) {
+	tests := []struct {
+		name  string
+		tweet Tweet
+		want  []Interaction
+	}{
+		{
+			name:  "mentions",
+			tweet: Tweet{Username: "Alice", Text: "gm @Bob and @carol, and @bob again; mail me at alice@example.com"},
+			want: []Interaction{
+				{Source: "alice", Target: "bob", Kind: interactionMention, Weight: 1},
+				{Source: "alice", Target: "carol", Kind: interactionMention, Weight: 1},
+			},
+		},
+		{
+			name:  "self mention",
+			tweet: Tweet{Username: "alice", Text: "follow @alice for more"},
+		},
+		{
+			name:  "reply",
+			tweet: Tweet{Username: "alice", InReplyToUsername: "bob", Text: "@bob @carol agreed, ask @dave"},
+			want: []Interaction{
+				{Source: "alice", Target: "bob", Kind: interactionReply, Weight: 1},
+				{Source: "alice", Target: "dave", Kind: interactionMention, Weight: 1},
+			},
+		},
+		{
+			name:  "quote",
+			tweet: Tweet{Username: "alice", QuotedUsername: "bob", Text: "this, by @carol"},
+			want: []Interaction{
+				{Source: "alice", Target: "bob", Kind: interactionQuote, Weight: 1},
+				{Source: "alice", Target: "carol", Kind: interactionMention, Weight: 1},
+			},
+		},
+		{
+			name:  "retweet",
+			tweet: Tweet{Username: "alice", RetweetedUsername: "Bob", Text: "RT @bob: thanks @carol"},
+			want:  []Interaction{{Source: "alice", Target: "bob", Kind: interactionRetweet, Weight: 1}},
+		},
+		{
+			name:  "retweet from its text",
+			tweet: Tweet{Username: "alice", Text: "RT @bob: thanks @carol"},
+			want:  []Interaction{{Source: "alice", Target: "bob", Kind: interactionRetweet, Weight: 1}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tweetInteractions(tt.tweet); !slices.Equal(got, tt.want) {
+				t.Errorf("interactions = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateInteractions(t *testing.T) {
+	got := aggregateInteractions([]Interaction{
+		{Source: "bob", Target: "alice", Kind: interactionReply, Weight: 1},
+		{Source: "alice", Target: "bob", Kind: interactionMention, Weight: 1},
+		{Source: "bob", Target: "alice", Kind: interactionReply, Weight: 2},
+		{Source: "alice", Target: "bob", Kind: interactionQuote, Weight: 1},
+	})
+	want := []Interaction{
+		{Source: "alice", Target: "bob", Kind: interactionMention, Weight: 1},
+		{Source: "alice", Target: "bob", Kind: interactionQuote, Weight: 1},
+		{Source: "bob", Target: "alice", Kind: interactionReply, Weight: 3},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("edges = %+v, want %+v", got, want)
+	}
+}
+
+// testGraph builds a graph from edges on one day, with alice, bob, carol and dave observed
+func testGraph(edges ...Interaction) *Graph {
+	g := &Graph{AccountsList: "test", StartDate: "2025-01-06", EndDate: "2025-01-06", Timezone: "UTC", Days: []string{"2025-01-06"}}
+	labels := map[string]string{"alice": "Alice", "bob": "bob", "carol": "carol", "dave": "dave"}
+	g.build([]DailyInteractions{{Date: "2025-01-06", Edges: edges}}, labels, false)
+	return g
+}
+
+func edge(source string, target string, weight int) Interaction {
+	return Interaction{Source: source, Target: target, Kind: interactionReply, Weight: weight}
+}
+
+func TestDetectCommunities(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		want  [][]string // the accounts of each community
+	}{
+		{
+			name:  "no edges",
+			graph: testGraph(),
+			want:  [][]string{{"alice"}, {"bob"}, {"carol"}, {"dave"}},
+		},
+		{
+			name:  "two pairs",
+			graph: testGraph(edge("alice", "bob", 3), edge("carol", "dave", 1), edge("dave", "carol", 1)),
+			want:  [][]string{{"alice", "bob"}, {"carol", "dave"}},
+		},
+		{
+			name: "two triangles and a weak link",
+			graph: testGraph(
+				edge("alice", "bob", 3), edge("bob", "x", 3), edge("x", "alice", 3),
+				edge("carol", "dave", 3), edge("dave", "y", 3), edge("y", "carol", 3),
+				edge("x", "y", 1),
+			),
+			want: [][]string{{"alice", "bob", "x"}, {"carol", "dave", "y"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			communities := make(map[int][]string)
+			for _, n := range tt.graph.Nodes {
+				communities[n.Community] = append(communities[n.Community], n.ID)
+			}
+			if tt.graph.Communities != len(tt.want) {
+				t.Errorf("%d communities, want %d", tt.graph.Communities, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !slices.Equal(communities[i], want) {
+					t.Errorf("community %d = %v, want %v", i, communities[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestGraphBuild(t *testing.T) {
+	g := testGraph(edge("alice", "bob", 2), edge("bob", "alice", 1), edge("alice", "x", 1))
+	nodes := make(map[string]*GraphNode)
+	for _, n := range g.Nodes {
+		nodes[n.ID] = n
+	}
+	if alice := nodes["alice"]; alice.Label != "Alice" || !alice.Observed || alice.OutDegree != 2 || alice.WeightedOut != 3 || alice.InDegree != 1 || alice.Reciprocity != 0.5 {
+		t.Errorf("alice = %+v", alice)
+	}
+	if x := nodes["x"]; x == nil || x.Observed || x.InDegree != 1 {
+		t.Errorf("x = %+v, want a node for the account outside the list", x)
+	}
+	if g.Reciprocity != 2.0/3 {
+		t.Errorf("reciprocity = %v, want 2/3", g.Reciprocity)
+	}
+	if g.Edges[0].Source != "alice" || g.Edges[0].Target != "bob" || g.Edges[0].Weight != 2 {
+		t.Errorf("heaviest edge = %+v, want alice to bob", g.Edges[0])
+	}
+
+	internal := &Graph{}
+	internal.build([]DailyInteractions{{Date: "2025-01-06", Edges: []Interaction{edge("alice", "bob", 2), edge("alice", "x", 1)}}}, map[string]string{"alice": "alice", "bob": "bob"}, true)
+	if len(internal.Edges) != 1 || len(internal.Nodes) != 2 {
+		t.Errorf("internal graph has %d edges and %d nodes, want only alice to bob", len(internal.Edges), len(internal.Nodes))
+	}
+}
+
+func TestCompareGraphs(t *testing.T) {
+	previous := testGraph(edge("alice", "bob", 2), edge("bob", "alice", 1), edge("carol", "dave", 4))
+	current := testGraph(edge("alice", "bob", 2), edge("bob", "alice", 3), edge("dave", "carol", 1))
+
+	change := compareGraphs(previous, current)
+	if want := []EdgeChange{{Source: "dave", Target: "carol", Current: 1}}; !slices.Equal(change.NewEdges, want) {
+		t.Errorf("new edges = %+v, want %+v", change.NewEdges, want)
+	}
+	if want := []EdgeChange{{Source: "carol", Target: "dave", Previous: 4}}; !slices.Equal(change.GoneEdges, want) {
+		t.Errorf("gone edges = %+v, want %+v", change.GoneEdges, want)
+	}
+	if want := []EdgeChange{{Source: "bob", Target: "alice", Previous: 1, Current: 3}}; !slices.Equal(change.Changed, want) {
+		t.Errorf("changed edges = %+v, want %+v", change.Changed, want)
+	}
+}
+
+func TestDailyInteractionsReuse(t *testing.T) {
+	tweets := []Tweet{
+		{ID: "1001", Username: "alice", CreatedAt: "2025-01-06 09:00:00", Text: "gm @bob"},
+		{ID: "1002", Username: "carol", CreatedAt: "2025-01-06 10:00:00", Text: "@alice what's next?", InReplyToUsername: "alice"},
+	}
+	day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	aliceToBob := Interaction{Source: "alice", Target: "bob", Kind: interactionMention, Weight: 1}
+	carolToAlice := Interaction{Source: "carol", Target: "alice", Kind: interactionReply, Weight: 1}
+
+	tests := []struct {
+		name string
+		// list is the accounts list when the interactions are asked for again, after being
+		// stored for alice and bob
+		list string
+		want []Interaction
+	}{
+		{"same accounts", "alice\nbob\n", []Interaction{aliceToBob}},
+		{"account promoted into the list", "alice\nbob\ncarol\n", []Interaction{aliceToBob, carolToAlice}},
+		{"account active from the day", `{"accounts": [{"handle": "alice"}, {"handle": "bob"}, {"handle": "carol", "active_from": "2025-01-06"}]}`, []Interaction{aliceToBob, carolToAlice}},
+		{"account active from a later day", `{"accounts": [{"handle": "alice"}, {"handle": "bob"}, {"handle": "carol", "active_from": "2025-01-07"}]}`, []Interaction{aliceToBob}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp(t, nil, tweets)
+			first, err := app.dailyInteractions(context.Background(), "test", day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(first.Edges, []Interaction{aliceToBob}) {
+				t.Fatalf("edges = %+v, want only alice's", first.Edges)
+			}
+			if _, err := app.reports.LoadDailyInteractions(context.Background(), "test", day); err != nil {
+				t.Fatalf("interactions weren't stored: %v", err)
+			}
+
+			name := "test.txt"
+			if tt.list[0] == '{' {
+				name = "test.json"
+			}
+			if err := os.WriteFile(filepath.Join(accountsDir, name), []byte(tt.list), 0644); err != nil {
+				t.Fatal(err)
+			}
+			again, err := app.dailyInteractions(context.Background(), "test", day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(again.Edges, tt.want) {
+				t.Errorf("edges = %+v, want %+v", again.Edges, tt.want)
+			}
+		})
+	}
+}
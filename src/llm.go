@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"net/http"
 	"strings"
 
 	openai "github.com/sashabaranov/go-openai"
@@ -16,22 +18,87 @@ var GPT4_o string = "gpt-4o-2024-05-13"
 var GPT4_turbo string = "gpt-4-turbo"
 var GPT4_o_mini string = "gpt-4o-mini"
 
-type OpenAIRequest struct {
-	prompt string
-	model  string
-	token  string
+// LLMRequest is a single-prompt chat completion
+type LLMRequest struct {
+	Model  string
+	Prompt string
 }
 
-func fetchOpenAIAnswer(req OpenAIRequest) (string, error) {
-	client := openai.NewClient(req.token)
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
+// LLMUsage is the number of tokens a request consumed
+type LLMUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// LLMResponse is the answer to an LLMRequest
+type LLMResponse struct {
+	Content string
+	Usage   LLMUsage
+	Cached  bool // served from the cache, so Usage wasn't paid for again
+	// discard, set by the cache, drops the answer from it
+	discard func()
+}
+
+// reject is called by a caller that can't use the answer, e.g. because it doesn't parse, so
+// that the cache doesn't serve it again
+func (r LLMResponse) reject() {
+	if r.discard != nil {
+		r.discard()
+	}
+}
+
+// LLMProvider is an LLM the reports can be written with
+type LLMProvider interface {
+	Name() string
+	// Complete returns the answer to a prompt as free text
+	Complete(ctx context.Context, req LLMRequest) (LLMResponse, error)
+	// CompleteJSON returns an answer that conforms to schema
+	CompleteJSON(ctx context.Context, req LLMRequest, schema openai.ChatCompletionResponseFormatJSONSchema) (LLMResponse, error)
+}
+
+// openAIProvider talks to the OpenAI API, or to any server that implements its chat completions
+type openAIProvider struct {
+	name   string
+	client *openai.Client
+}
+
+func newOpenAIProvider(token string) *openAIProvider {
+	config := openai.DefaultConfig(token)
+	config.HTTPClient = &http.Client{Transport: &retryAfterTransport{base: http.DefaultTransport}}
+	return &openAIProvider{name: "openai", client: openai.NewClientWithConfig(config)}
+}
+
+// newOpenAICompatibleProvider talks to an OpenAI-compatible server at baseURL,
+// e.g. http://localhost:11434/v1 for ollama or http://localhost:8080/v1 for llama.cpp
+func newOpenAICompatibleProvider(baseURL string, token string) *openAIProvider {
+	config := openai.DefaultConfig(token)
+	config.BaseURL = strings.TrimSuffix(baseURL, "/")
+	config.HTTPClient = &http.Client{Transport: &retryAfterTransport{base: http.DefaultTransport}}
+	return &openAIProvider{name: "openai-compatible", client: openai.NewClientWithConfig(config)}
+}
+
+func (p *openAIProvider) Name() string {
+	return p.name
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, req LLMRequest) (LLMResponse, error) {
+	return p.fetchOpenAIAnswer(ctx, req)
+}
+
+func (p *openAIProvider) CompleteJSON(ctx context.Context, req LLMRequest, schema openai.ChatCompletionResponseFormatJSONSchema) (LLMResponse, error) {
+	return p.fetchOpenAIAnswerJSON(ctx, req, schema)
+}
+
+func (p *openAIProvider) fetchOpenAIAnswer(ctx context.Context, req LLMRequest) (LLMResponse, error) {
+	ctx, retryAfter := withRetryAfterHint(ctx)
+	resp, err := p.client.CreateChatCompletion(
+		ctx,
 		openai.ChatCompletionRequest{
-			Model: req.model,
+			Model: req.Model,
 			Messages: []openai.ChatCompletionMessage{
 				{
 					Role:    openai.ChatMessageRoleUser,
-					Content: req.prompt,
+					Content: req.Prompt,
 				},
 			},
 		},
@@ -39,24 +106,30 @@ func fetchOpenAIAnswer(req OpenAIRequest) (string, error) {
 
 	if err != nil {
 		log.Printf("ChatCompletion error: %v\n", err)
-		return "", err
+		return LLMResponse{}, classifyLLMError(err, retryAfter.get())
+	}
+	if len(resp.Choices) == 0 {
+		return LLMResponse{}, &LLMError{Kind: LLMErrorEmptyResponse, Err: fmt.Errorf("no choices in response to model %s", req.Model)}
 	}
 
-	result := resp.Choices[0].Message.Content
+	result := LLMResponse{
+		Content: resp.Choices[0].Message.Content,
+		Usage:   LLMUsage{PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens},
+	}
 	return result, nil
 }
 
 
-func fetchOpenAIAnswerJSON(req OpenAIRequest, schema openai.ChatCompletionResponseFormatJSONSchema) (string, error) {
-	client := openai.NewClient(req.token)
-	resp, err := client.CreateChatCompletion(
-		context.Background(),
+func (p *openAIProvider) fetchOpenAIAnswerJSON(ctx context.Context, req LLMRequest, schema openai.ChatCompletionResponseFormatJSONSchema) (LLMResponse, error) {
+	ctx, retryAfter := withRetryAfterHint(ctx)
+	resp, err := p.client.CreateChatCompletion(
+		ctx,
 		openai.ChatCompletionRequest{
-			Model: req.model,
+			Model: req.Model,
 			Messages: []openai.ChatCompletionMessage{
 				{
 					Role:    openai.ChatMessageRoleUser,
-					Content: req.prompt,
+					Content: req.Prompt,
 				},
 			},
 			ResponseFormat: &openai.ChatCompletionResponseFormat{
@@ -68,28 +141,50 @@ func fetchOpenAIAnswerJSON(req OpenAIRequest, schema openai.ChatCompletionRespon
 
 	if err != nil {
 		log.Printf("ChatCompletion error: %v\n", err)
-		return "", err
+		return LLMResponse{}, classifyLLMError(err, retryAfter.get())
+	}
+	if len(resp.Choices) == 0 {
+		return LLMResponse{}, &LLMError{Kind: LLMErrorEmptyResponse, Err: fmt.Errorf("no choices in response to model %s", req.Model)}
 	}
 
-	result := resp.Choices[0].Message.Content
+	result := LLMResponse{
+		Content: resp.Choices[0].Message.Content,
+		Usage:   LLMUsage{PromptTokens: resp.Usage.PromptTokens, CompletionTokens: resp.Usage.CompletionTokens},
+	}
 	return result, nil
 }
 
 type SummaryBox struct {
-	Summary string  `json:"summary"`
-	Error   *string `json:"error"`
+	Summary string     `json:"summary"`
+	Claims  []ClaimBox `json:"claims"`
+	Error   *string    `json:"error"`
 }
 
-func Summarize(text string, token string) (string, error) {
-	prompt := "Please provide a mostly concise summary of the following Twitter activity. Focus on:\n" +
-		"1. Key themes and topics discussed\n" +
-		"2. Notable patterns in posting behavior\n" +
-		"3. Important mentions or interactions\n" +
-		"4. Overall sentiment and tone\n" +
-		"5. Any significant events or announcements\n\n" +
-	  "If there are any highly significant events or developments, spend a paragraph on them, but not more.\n\n" + 
-		"Provide the response as JSON with this format: {\"summary\": \"your detailed summary here\", \"error\": null}\n\n" +
-		"Twitter Activity Data:\n" + text
+// ClaimBox is a claim made by a summary, with the tweets the LLM says support it
+type ClaimBox struct {
+	Text     string   `json:"text"`
+	TweetIDs []string `json:"tweet_ids"`
+}
+
+// summarizeInstructions precede the data in Summarize's prompt
+const summarizeInstructions = "Please provide a mostly concise summary of the following Twitter activity. Focus on:\n" +
+	"1. Key themes and topics discussed\n" +
+	"2. Notable patterns in posting behavior\n" +
+	"3. Important mentions or interactions\n" +
+	"4. Overall sentiment and tone\n" +
+	"5. Any significant events or announcements\n\n" +
+	"If there are any highly significant events or developments, spend a paragraph on them, but not more.\n\n" + 
+	"Each tweet in the data is a line with its tweet_id in square brackets and its text as a JSON string, e.g. [1795098471846101134] \"gm\". " +
+	"List every factual claim your summary makes under \"claims\", each with the tweet_ids of the tweets that support it. " +
+	"Only cite tweet_ids that appear in the data.\n\n" +
+	"Provide the response as JSON with this format: {\"summary\": \"your detailed summary here\", \"claims\": [{\"text\": \"a claim\", \"tweet_ids\": [\"1795098471846101134\"]}], \"error\": null}\n\n" +
+	"Twitter Activity Data:\n"
+
+// Summarize summarizes text, and lists the claims of the summary with the IDs of the
+// tweets they are based on. Tweets in text are lines written by formatCitedLine. The text is
+// untrusted, so it's delimited and the LLM told not to follow instructions in it.
+func Summarize(ctx context.Context, llm LLMProvider, model string, text string) (string, []ClaimBox, error) {
+	prompt := summarizeInstructions + wrapData(text)
 
 
 	var summary_box SummaryBox
@@ -102,32 +197,120 @@ func Summarize(text string, token string) (string, error) {
 		Schema: schema,
 		Strict: true,
 	}
-	summary_json, err := fetchOpenAIAnswerJSON(OpenAIRequest{prompt: prompt, model: GPT4_o_mini, token: token}, openai_schema)
+	response, err := llm.CompleteJSON(ctx, LLMRequest{Model: model, Prompt: prompt}, openai_schema)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
+	summary_json := response.Content
 	
 	err = json.Unmarshal([]byte(summary_json), &summary_box)
 	if err != nil {
+		response.reject()
 		log.Printf("Error unmarshalling json: %v", err)
 		log.Printf("String was: %v", summary_json)
-		return "", err
+		return "", nil, err
+	}
+	return summary_box.Summary, summary_box.Claims, nil
+}
+
+type VerdictsBox struct {
+	Verdicts []VerdictBox `json:"verdicts"`
+}
+
+// VerdictBox says whether the tweets support the claim with the given number
+type VerdictBox struct {
+	Claim     int    `json:"claim"`
+	Supported bool   `json:"supported"`
+	Reason    string `json:"reason"`
+}
+
+// checkClaimsInstructions precede the claims and tweets in CheckClaims' prompt
+const checkClaimsInstructions = "You are checking a summary of someone's tweets for faithfulness. For each numbered claim below, " +
+	"decide whether the tweets support it: a claim is supported only if the tweets state or directly imply it. " +
+	"Claims about events are supported if the tweets report them, even if the tweets may be wrong; " +
+	"claims that add facts, numbers or outcomes the tweets don't contain are not.\n\n" +
+	"Answer with one verdict per claim, with its number, whether it is supported, and a short reason.\n\n"
+
+// CheckClaims asks the LLM which of the numbered claims are supported by tweets, a list of
+// lines that start with [tweet_id], as written by formatCitedLine
+func CheckClaims(ctx context.Context, llm LLMProvider, model string, claims []string, tweets string) ([]VerdictBox, error) {
+	var numbered strings.Builder
+	for i, claim := range claims {
+		fmt.Fprintf(&numbered, "%d. %s\n", i+1, quoteData(claim))
+	}
+	prompt := checkClaimsInstructions + "Claims, as JSON strings:\n" + numbered.String() + "\n" +
+		"Tweets, each its tweet_id followed by its text as a JSON string:\n" + wrapData(tweets)
+
+	var verdicts_box VerdictsBox
+	schema, err := jsonschema.GenerateSchemaForType(verdicts_box)
+	if err != nil {
+		return nil, fmt.Errorf("GenerateSchemaForType error: %v", err)
+	}
+	response, err := llm.CompleteJSON(ctx, LLMRequest{Model: model, Prompt: prompt}, openai.ChatCompletionResponseFormatJSONSchema{
+		Name:   "Verdicts",
+		Schema: schema,
+		Strict: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(response.Content), &verdicts_box); err != nil {
+		response.reject()
+		return nil, fmt.Errorf("failed to parse verdicts: %v", err)
+	}
+	return verdicts_box.Verdicts, nil
+}
+
+// ClassificationBox is the LLM's judgement of whether an account is run by an AI agent
+type ClassificationBox struct {
+	IsAgent     bool    `json:"is_agent"`
+	Probability float64 `json:"probability"` // that the account is an agent, from 0 to 1
+	Category    string  `json:"category"`
+	Reason      string  `json:"reason"`
+}
+
+// ClassifyAccount asks the LLM whether handle is an AI agent, from its tweets, lines
+// written by formatCitedLine
+func ClassifyAccount(ctx context.Context, llm LLMProvider, model string, handle string, tweets string) (ClassificationBox, error) {
+	prompt := "You are helping find Twitter accounts that are run by AI agents, i.e. where an LLM or other automated system writes the tweets. " +
+		"Judge from the tweets of @" + handle + " below whether the account is such an agent. " +
+		"Signs include a stated identity as an AI, uniform style and length, formulaic openings, constant replies at all hours, " +
+		"and posting about its own model or operator; a human talking about AI is not an agent.\n\n" +
+		"Answer whether it is an agent, the probability from 0 to 1 that it is, a short category " +
+		"(e.g. crypto-agent, corporate-agent, meme, assistant, human) and a one-sentence reason.\n\n" +
+		"Tweets, each its tweet_id followed by its text as a JSON string:\n" + wrapData(tweets)
+
+	var classification_box ClassificationBox
+	schema, err := jsonschema.GenerateSchemaForType(classification_box)
+	if err != nil {
+		return ClassificationBox{}, fmt.Errorf("GenerateSchemaForType error: %v", err)
+	}
+	response, err := llm.CompleteJSON(ctx, LLMRequest{Model: model, Prompt: prompt}, openai.ChatCompletionResponseFormatJSONSchema{
+		Name:   "Classification",
+		Schema: schema,
+		Strict: true,
+	})
+	if err != nil {
+		return ClassificationBox{}, err
+	}
+	if err := json.Unmarshal([]byte(response.Content), &classification_box); err != nil {
+		response.reject()
+		return ClassificationBox{}, fmt.Errorf("failed to parse classification: %v", err)
 	}
-	summary := summary_box.Summary
-	return summary, nil
+	return classification_box, nil
 }
 
-func TranslateString(text string, token string) (string, error) {
+func TranslateString(ctx context.Context, llm LLMProvider, text string) (string, error) {
 	prompt := "Translate this text into English: " + text + "\n"
-	translation, err := fetchOpenAIAnswer(OpenAIRequest{prompt: prompt, model: GPT4_turbo, token: token})
+	translation, err := llm.Complete(ctx, LLMRequest{Model: GPT4_turbo, Prompt: prompt})
 	if err != nil {
 		return "", err
 	}
-	translation_trimmed := strings.TrimSpace(translation)
+	translation_trimmed := strings.TrimSpace(translation.Content)
 	return translation_trimmed, nil
 }
 
-func MergeArticles(text string, token string) (string, error) {
+func MergeArticles(ctx context.Context, llm LLMProvider, text string) (string, error) {
 	prompt := "Consider the following list of articles and their summaries. Your task is to clean it up.\n\n" +
 		"1. If there are many articles, add a tl;dr at the top with the events which would most likely end up with > 1M deaths. Make this a paragraph starting with <p><b>tl;dr:</b>..., not an h1 element\n" +
 		"2. Some of the articles may be talking about the same event—if so, join them together in one subsection, merge their summaries and reasoning, and create a list of the links that point to the same event. Otherwise, repeat the content of each item.\n" +
@@ -135,9 +318,9 @@ func MergeArticles(text string, token string) (string, error) {
 		"4. If do some other type of cleanup, point it out at the end.\n\n" +
 		"Don't acknowledge instructions, just answer with the html.\n\n" + text
 
-	summary, err := fetchOpenAIAnswer(OpenAIRequest{prompt: prompt, model: GPT4_turbo, token: token})
+	summary, err := llm.Complete(ctx, LLMRequest{Model: GPT4_turbo, Prompt: prompt})
 	if err != nil {
 		return "", err
 	}
-	return summary + "<details><summary>The above articles were merged by GPT4-turbo. But you can view the originals under this toggle</summary>" + text + "</details>", nil
+	return summary.Content + "<details><summary>The above articles were merged by GPT4-turbo. But you can view the originals under this toggle</summary>" + text + "</details>", nil
 }
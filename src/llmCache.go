@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// cachingProvider wraps an LLMProvider with a persistent, content-addressed cache: the same
+// provider, endpoint, model, prompt and schema get the stored answer instead of a new request, so
+// re-running overlapping windows and backfills costs nothing and gives the same reports.
+// JSON answers that don't parse are never stored, and answers their caller rejects are dropped.
+type cachingProvider struct {
+	LLMProvider
+	baseURL  string // the provider's endpoint, since two local servers can serve different models under one name
+	dir      string
+	ttl      time.Duration // entries older than this are ignored; 0 means they never expire
+	noLookup bool          // don't read the cache, but still store fresh answers in it
+}
+
+// llmCacheEntry is the file stored for each cached answer
+type llmCacheEntry struct {
+	CreatedAt time.Time `json:"created_at"`
+	Provider  string    `json:"provider"`
+	BaseURL   string    `json:"base_url,omitempty"`
+	Model     string    `json:"model"`
+	Response  string    `json:"response"`
+	Usage     LLMUsage  `json:"usage"` // what the original request cost
+}
+
+func newCachingProvider(provider LLMProvider, baseURL string, dir string, ttl time.Duration, noLookup bool) (*cachingProvider, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create LLM cache directory: %v", err)
+	}
+	return &cachingProvider{LLMProvider: provider, baseURL: baseURL, dir: dir, ttl: ttl, noLookup: noLookup}, nil
+}
+
+// cacheKey hashes everything that determines the answer
+func (p *cachingProvider) cacheKey(req LLMRequest, schema *openai.ChatCompletionResponseFormatJSONSchema) (string, error) {
+	var schemaJSON []byte
+	if schema != nil {
+		var err error
+		if schemaJSON, err = json.Marshal(schema); err != nil {
+			return "", fmt.Errorf("failed to marshal schema: %v", err)
+		}
+	}
+	key, err := json.Marshal(struct {
+		Provider string          `json:"provider"`
+		BaseURL  string          `json:"base_url,omitempty"`
+		Model    string          `json:"model"`
+		Prompt   string          `json:"prompt"`
+		Schema   json.RawMessage `json:"schema,omitempty"`
+	}{p.Name(), p.baseURL, req.Model, req.Prompt, schemaJSON})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (p *cachingProvider) path(key string) string {
+	return filepath.Join(p.dir, key[:2], key+".json")
+}
+
+func (p *cachingProvider) lookup(key string) (LLMResponse, bool) {
+	if p.noLookup {
+		return LLMResponse{}, false
+	}
+	data, err := os.ReadFile(p.path(key))
+	if err != nil {
+		return LLMResponse{}, false
+	}
+	var entry llmCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return LLMResponse{}, false
+	}
+	if p.ttl > 0 && time.Since(entry.CreatedAt) > p.ttl {
+		return LLMResponse{}, false
+	}
+	return LLMResponse{Content: entry.Response, Usage: entry.Usage, Cached: true, discard: func() { p.discard(key) }}, true
+}
+
+// discard drops an entry, so that the next request for it asks the provider again
+func (p *cachingProvider) discard(key string) {
+	if err := os.Remove(p.path(key)); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Warning: failed to remove LLM cache entry: %v\n", err)
+	}
+}
+
+// store saves an answer. Failing to cache isn't worth failing the run for, so errors are only reported.
+func (p *cachingProvider) store(key string, req LLMRequest, response LLMResponse) {
+	data, err := json.MarshalIndent(llmCacheEntry{
+		CreatedAt: time.Now().UTC(),
+		Provider:  p.Name(),
+		BaseURL:   p.baseURL,
+		Model:     req.Model,
+		Response:  response.Content,
+		Usage:     response.Usage,
+	}, "", "  ")
+	if err != nil {
+		fmt.Printf("Warning: failed to encode LLM cache entry: %v\n", err)
+		return
+	}
+	path := p.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		fmt.Printf("Warning: failed to create LLM cache directory: %v\n", err)
+		return
+	}
+	// Write then rename, so concurrent workers never read half an entry
+	tmpPath := fmt.Sprintf("%s.%d.tmp", path, time.Now().UnixNano())
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		fmt.Printf("Warning: failed to write LLM cache entry: %v\n", err)
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		fmt.Printf("Warning: failed to write LLM cache entry: %v\n", err)
+	}
+}
+
+func (p *cachingProvider) Complete(ctx context.Context, req LLMRequest) (LLMResponse, error) {
+	key, err := p.cacheKey(req, nil)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	if response, ok := p.lookup(key); ok {
+		return response, nil
+	}
+	response, err := p.LLMProvider.Complete(ctx, req)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	p.store(key, req, response)
+	response.discard = func() { p.discard(key) }
+	return response, nil
+}
+
+func (p *cachingProvider) CompleteJSON(ctx context.Context, req LLMRequest, schema openai.ChatCompletionResponseFormatJSONSchema) (LLMResponse, error) {
+	key, err := p.cacheKey(req, &schema)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	if response, ok := p.lookup(key); ok {
+		return response, nil
+	}
+	response, err := p.LLMProvider.CompleteJSON(ctx, req, schema)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	// A truncated or malformed answer would fail every rerun until it expired
+	if !json.Valid([]byte(response.Content)) {
+		return response, nil
+	}
+	p.store(key, req, response)
+	response.discard = func() { p.discard(key) }
+	return response, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheKey(t *testing.T) {
+	req := LLMRequest{Model: "llama3.1", Prompt: "Summarize these tweets"}
+	base, err := newCachingProvider(newFakeProvider(), "http://localhost:11434/v1", t.TempDir(), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseKey, err := base.cacheKey(req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		baseURL  string
+		req      LLMRequest
+		wantSame bool
+	}{
+		{"same request", "http://localhost:11434/v1", req, true},
+		{"other server", "http://localhost:8080/v1", req, false},
+		{"no base URL", "", req, false},
+		{"other model", "http://localhost:11434/v1", LLMRequest{Model: "qwen2.5", Prompt: req.Prompt}, false},
+		{"other prompt", "http://localhost:11434/v1", LLMRequest{Model: req.Model, Prompt: "Summarize these replies"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newCachingProvider(newFakeProvider(), tt.baseURL, t.TempDir(), 0, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := p.cacheKey(tt.req, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (key == baseKey) != tt.wantSame {
+				t.Errorf("key %s, base key %s: same = %v, want %v", key, baseKey, key == baseKey, tt.wantSame)
+			}
+		})
+	}
+}
+
+// ageCacheEntries makes every entry in the cache at dir look age old
+func ageCacheEntries(t *testing.T, dir string, age time.Duration) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var entry llmCacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			t.Fatal(err)
+		}
+		entry.CreatedAt = entry.CreatedAt.Add(-age)
+		if data, err = json.Marshal(entry); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCachingProvider(t *testing.T) {
+	valid := `{"summary": "Alice shipped version 2.0.", "claims": [], "error": null}`
+
+	tests := []struct {
+		name     string
+		answer   string // the provider's answer to Summarize
+		ttl      time.Duration
+		noLookup bool
+		age      time.Duration // how old the entries are by the second call
+		// wantCalls is how many of the two calls reached the provider, and wantStored whether
+		// an entry is left in the cache
+		wantCalls  int
+		wantStored bool
+		wantErr    bool
+	}{
+		{name: "stored and served", answer: valid, wantCalls: 1, wantStored: true},
+		{name: "within the TTL", answer: valid, ttl: 24 * time.Hour, age: time.Hour, wantCalls: 1, wantStored: true},
+		{name: "expired", answer: valid, ttl: 24 * time.Hour, age: 48 * time.Hour, wantCalls: 2, wantStored: true},
+		{name: "no lookup", answer: valid, noLookup: true, wantCalls: 2, wantStored: true},
+		{name: "truncated answer isn't stored", answer: `{"summary": "Alice shipped`, wantCalls: 2, wantErr: true},
+		{name: "answer the caller can't parse is dropped", answer: `{"summary": 2.0, "claims": [], "error": null}`, wantCalls: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fake := newFakeProvider().withResponse("Summary", tt.answer)
+			p, err := newCachingProvider(fake, "", dir, tt.ttl, tt.noLookup)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 2; i++ {
+				if i == 1 {
+					ageCacheEntries(t, dir, tt.age)
+				}
+				summary, _, err := Summarize(context.Background(), p, "local-model", "[1001] \"Shipping version 2.0\"")
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("call %d: error = %v, want an error: %v", i+1, err, tt.wantErr)
+				}
+				if err == nil && summary != "Alice shipped version 2.0." {
+					t.Errorf("call %d: summary = %q", i+1, summary)
+				}
+			}
+
+			if calls := len(fake.Calls()); calls != tt.wantCalls {
+				t.Errorf("the provider was called %d times, want %d", calls, tt.wantCalls)
+			}
+			entries, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored := len(entries) > 0; stored != tt.wantStored {
+				t.Errorf("cache holds %d entries, want an entry: %v", len(entries), tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestCachingProviderMarksCachedAnswers(t *testing.T) {
+	p, err := newCachingProvider(newFakeProvider(), "", t.TempDir(), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := LLMRequest{Model: "local-model", Prompt: "Translate this text into English: hola"}
+	first, err := p.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := p.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Cached || !second.Cached || second.Content != first.Content || second.Usage != first.Usage {
+		t.Errorf("first answer %+v, second %+v, want the second served from the cache with the first's usage", first, second)
+	}
+}
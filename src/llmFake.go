@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// fakeProvider is a deterministic LLMProvider that never touches the network, for running
+// the report pipeline in tests and offline. The same request always gets the same answer.
+type fakeProvider struct {
+	// responses maps a JSON schema name ("" for plain completions) to a canned answer.
+	// Requests without a canned answer get one generated from the prompt.
+	responses map[string]string
+	// Once failAfter calls have been answered, every call fails with failErr
+	failAfter int
+	failErr   error
+
+	mu    sync.Mutex
+	calls []LLMRequest
+}
+
+func newFakeProvider() *fakeProvider {
+	return &fakeProvider{responses: make(map[string]string)}
+}
+
+// withResponse makes the provider answer requests for the JSON schema name, or plain
+// completions if name is "", with content. It returns the provider, so calls can be chained.
+func (p *fakeProvider) withResponse(name string, content string) *fakeProvider {
+	p.responses[name] = content
+	return p
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+// withFailure makes every call after the first after fail with err, the way a provider that
+// runs out of budget or goes down partway through a run does. It returns the provider.
+func (p *fakeProvider) withFailure(after int, err error) *fakeProvider {
+	p.failAfter, p.failErr = after, err
+	return p
+}
+
+// Calls returns the requests the provider has answered, in order
+func (p *fakeProvider) Calls() []LLMRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]LLMRequest(nil), p.calls...)
+}
+
+// record notes an answered request, or returns the failure the request gets instead
+func (p *fakeProvider) record(req LLMRequest) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failErr != nil && len(p.calls) >= p.failAfter {
+		return p.failErr
+	}
+	p.calls = append(p.calls, req)
+	return nil
+}
+
+func (p *fakeProvider) Complete(ctx context.Context, req LLMRequest) (LLMResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return LLMResponse{}, err
+	}
+	if err := p.record(req); err != nil {
+		return LLMResponse{}, err
+	}
+	if response, ok := p.responses[""]; ok {
+		return fakeResponse(req, response), nil
+	}
+	return fakeResponse(req, fakeText(req.Prompt)), nil
+}
+
+func (p *fakeProvider) CompleteJSON(ctx context.Context, req LLMRequest, schema openai.ChatCompletionResponseFormatJSONSchema) (LLMResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return LLMResponse{}, err
+	}
+	if err := p.record(req); err != nil {
+		return LLMResponse{}, err
+	}
+	if response, ok := p.responses[schema.Name]; ok {
+		return fakeResponse(req, response), nil
+	}
+
+	schemaJSON, err := json.Marshal(schema.Schema)
+	if err != nil {
+		return LLMResponse{}, fmt.Errorf("fake provider: failed to marshal schema %s: %v", schema.Name, err)
+	}
+	var definition map[string]any
+	if err := json.Unmarshal(schemaJSON, &definition); err != nil {
+		return LLMResponse{}, fmt.Errorf("fake provider: failed to read schema %s: %v", schema.Name, err)
+	}
+
+	answer, err := json.Marshal(fakeValue(definition, req.Prompt))
+	if err != nil {
+		return LLMResponse{}, fmt.Errorf("fake provider: failed to marshal answer: %v", err)
+	}
+	return fakeResponse(req, string(answer)), nil
+}
+
+// fakeResponse reports token usage as estimated from the prompt and answer sizes
+func fakeResponse(req LLMRequest, content string) LLMResponse {
+	return LLMResponse{
+		Content: content,
+		Usage:   LLMUsage{PromptTokens: estimateTokens(req.Prompt), CompletionTokens: estimateTokens(content)},
+	}
+}
+
+// fakeText describes the prompt by its size and hash, so different inputs get different answers
+func fakeText(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	lines := strings.Count(prompt, "\n") + 1
+	return fmt.Sprintf("Fake summary of %d lines of input (%s).", lines, hex.EncodeToString(sum[:4]))
+}
+
+// fakeValue builds a value that conforms to a JSON schema definition. Nullable
+// fields are null, arrays are empty, and strings are fakeText of the prompt.
+func fakeValue(definition map[string]any, prompt string) any {
+	if enum, ok := definition["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+
+	var types []string
+	switch t := definition["type"].(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+	}
+	for _, t := range types {
+		if t == "null" {
+			return nil
+		}
+	}
+	if len(types) == 0 {
+		return nil
+	}
+
+	switch types[0] {
+	case "object":
+		object := make(map[string]any)
+		properties, _ := definition["properties"].(map[string]any)
+		for name, property := range properties {
+			if propertyDefinition, ok := property.(map[string]any); ok {
+				object[name] = fakeValue(propertyDefinition, prompt)
+			}
+		}
+		return object
+	case "array":
+		return []any{}
+	case "string":
+		return fakeText(prompt)
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// LLMErrorKind classifies why an LLM call failed, which decides whether it's worth retrying
+type LLMErrorKind string
+
+const (
+	LLMErrorRateLimited    LLMErrorKind = "rate_limited"    // 429: retry after a while
+	LLMErrorServer         LLMErrorKind = "server"          // 5xx: retry
+	LLMErrorNetwork        LLMErrorKind = "network"         // connection or timeout: retry
+	LLMErrorEmptyResponse  LLMErrorKind = "empty_response"  // no choices in the answer: retry
+	LLMErrorQuota          LLMErrorKind = "quota"           // out of credits: give up
+	LLMErrorAuth           LLMErrorKind = "auth"            // bad key: give up
+	LLMErrorInvalidRequest LLMErrorKind = "invalid_request" // 4xx: give up
+	LLMErrorCanceled       LLMErrorKind = "canceled"        // the run was cancelled
+	LLMErrorUnknown        LLMErrorKind = "unknown"
+)
+
+// errLLMErrorBudgetExhausted is returned for every LLM call once too many calls have failed,
+// so a run against a broken provider stops instead of producing a report full of gaps
+var errLLMErrorBudgetExhausted = errors.New("too many failed LLM calls in this run")
+
+// LLMError is a classified LLM failure
+type LLMError struct {
+	Kind       LLMErrorKind
+	StatusCode int           // HTTP status, if there was a response
+	RetryAfter time.Duration // how long the server asked us to wait, if it did
+	Err        error
+}
+
+func (e *LLMError) Error() string {
+	if e.StatusCode > 0 {
+		return fmt.Sprintf("LLM %s error (HTTP %d): %v", e.Kind, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("LLM %s error: %v", e.Kind, e.Err)
+}
+
+func (e *LLMError) Unwrap() error {
+	return e.Err
+}
+
+func (e *LLMError) Retryable() bool {
+	switch e.Kind {
+	case LLMErrorRateLimited, LLMErrorServer, LLMErrorNetwork, LLMErrorEmptyResponse:
+		return true
+	}
+	return false
+}
+
+// classifyLLMError turns an error from the OpenAI client into an *LLMError
+func classifyLLMError(err error, retryAfter time.Duration) *LLMError {
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		return llmErr
+	}
+
+	classified := &LLMError{Kind: LLMErrorUnknown, RetryAfter: retryAfter, Err: err}
+	var apiErr *openai.APIError
+	var requestErr *openai.RequestError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		classified.Kind = LLMErrorCanceled
+	case errors.As(err, &apiErr):
+		classified.StatusCode = apiErr.HTTPStatusCode
+		classified.Kind = kindForStatus(apiErr.HTTPStatusCode)
+		if code, ok := apiErr.Code.(string); ok && code == "insufficient_quota" {
+			classified.Kind = LLMErrorQuota
+		}
+	case errors.As(err, &requestErr):
+		classified.StatusCode = requestErr.HTTPStatusCode
+		classified.Kind = kindForStatus(requestErr.HTTPStatusCode)
+	case errors.As(err, &netErr), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		classified.Kind = LLMErrorNetwork
+	}
+	return classified
+}
+
+func kindForStatus(status int) LLMErrorKind {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return LLMErrorRateLimited
+	case status == http.StatusRequestTimeout:
+		return LLMErrorNetwork
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return LLMErrorAuth
+	case status >= 500:
+		return LLMErrorServer
+	case status >= 400:
+		return LLMErrorInvalidRequest
+	}
+	return LLMErrorUnknown
+}
+
+// retryAfterHint carries the Retry-After header of a failed response back to the caller,
+// since the OpenAI client doesn't expose the headers of error responses
+type retryAfterHint struct {
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+type retryAfterHintKey struct{}
+
+func withRetryAfterHint(ctx context.Context) (context.Context, *retryAfterHint) {
+	hint := &retryAfterHint{}
+	return context.WithValue(ctx, retryAfterHintKey{}, hint), hint
+}
+
+func (h *retryAfterHint) get() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.delay
+}
+
+// retryAfterTransport records the Retry-After header of 429 and 503 responses in the
+// request's retryAfterHint
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return resp, err
+	}
+	if hint, ok := req.Context().Value(retryAfterHintKey{}).(*retryAfterHint); ok {
+		if delay, ok := parseRetryAfter(resp.Header); ok {
+			hint.mu.Lock()
+			hint.delay = delay
+			hint.mu.Unlock()
+		}
+	}
+	return resp, err
+}
+
+// parseRetryAfter reads retry-after-ms, which OpenAI sends, or Retry-After in seconds or as a date
+func parseRetryAfter(header http.Header) (time.Duration, bool) {
+	if ms, err := strconv.ParseFloat(header.Get("Retry-After-Ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// retryingProvider retries retryable failures with exponential backoff and full jitter,
+// waiting at least as long as the server's Retry-After. It also keeps the run's error
+// budget: after maxFailures calls have failed for good, every call fails immediately.
+type retryingProvider struct {
+	LLMProvider
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	maxFailures int64 // 0 means no budget
+	failures    atomic.Int64
+	sleep       func(ctx context.Context, d time.Duration) error // waits between attempts
+}
+
+func newRetryingProvider(provider LLMProvider, maxAttempts int, maxFailures int) *retryingProvider {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &retryingProvider{
+		LLMProvider: provider,
+		maxAttempts: maxAttempts,
+		baseDelay:   time.Second,
+		maxDelay:    time.Minute,
+		maxFailures: int64(maxFailures),
+		sleep:       sleepContext,
+	}
+}
+
+// sleepContext waits for d, or until ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (p *retryingProvider) Complete(ctx context.Context, req LLMRequest) (LLMResponse, error) {
+	return p.retry(ctx, func() (LLMResponse, error) {
+		return p.LLMProvider.Complete(ctx, req)
+	})
+}
+
+func (p *retryingProvider) CompleteJSON(ctx context.Context, req LLMRequest, schema openai.ChatCompletionResponseFormatJSONSchema) (LLMResponse, error) {
+	return p.retry(ctx, func() (LLMResponse, error) {
+		return p.LLMProvider.CompleteJSON(ctx, req, schema)
+	})
+}
+
+func (p *retryingProvider) retry(ctx context.Context, call func() (LLMResponse, error)) (LLMResponse, error) {
+	if p.maxFailures > 0 && p.failures.Load() >= p.maxFailures {
+		return LLMResponse{}, errLLMErrorBudgetExhausted
+	}
+
+	var lastErr *LLMError
+	for attempt := 1; attempt <= p.maxAttempts; attempt++ {
+		response, err := call()
+		if err == nil {
+			return response, nil
+		}
+		lastErr = classifyLLMError(err, 0)
+		if lastErr.Kind == LLMErrorCanceled || ctx.Err() != nil {
+			return LLMResponse{}, err
+		}
+		if !lastErr.Retryable() || attempt == p.maxAttempts {
+			break
+		}
+
+		delay := p.backoff(attempt)
+		if lastErr.RetryAfter > delay {
+			delay = lastErr.RetryAfter
+		}
+		fmt.Printf("LLM call failed (attempt %d/%d, %s), retrying in %s...\n", attempt, p.maxAttempts, lastErr.Kind, delay.Round(time.Millisecond))
+		if err := p.sleep(ctx, delay); err != nil {
+			return LLMResponse{}, err
+		}
+	}
+
+	if failures := p.failures.Add(1); p.maxFailures > 0 && failures >= p.maxFailures {
+		return LLMResponse{}, fmt.Errorf("%w: %w", errLLMErrorBudgetExhausted, lastErr)
+	}
+	return LLMResponse{}, lastErr
+}
+
+// backoff is a random delay up to baseDelay * 2^(attempt-1), capped at maxDelay
+func (p *retryingProvider) backoff(attempt int) time.Duration {
+	ceiling := p.baseDelay << (attempt - 1)
+	if ceiling > p.maxDelay || ceiling <= 0 {
+		ceiling = p.maxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestClassifyLLMError(t *testing.T) {
+	server := &LLMError{Kind: LLMErrorServer, Err: errors.New("503")}
+	tests := []struct {
+		name      string
+		err       error
+		want      LLMErrorKind
+		wantRetry bool
+	}{
+		{"rate limited", &openai.APIError{HTTPStatusCode: 429, Message: "slow down"}, LLMErrorRateLimited, true},
+		{"server error", &openai.APIError{HTTPStatusCode: 500}, LLMErrorServer, true},
+		{"request error", fmt.Errorf("send: %w", &openai.RequestError{HTTPStatusCode: 502, Err: errors.New("bad gateway")}), LLMErrorServer, true},
+		{"request timeout", &openai.RequestError{HTTPStatusCode: 408, Err: errors.New("timeout")}, LLMErrorNetwork, true},
+		{"network", &net.DNSError{Err: "no such host", Name: "api.openai.com"}, LLMErrorNetwork, true},
+		{"connection closed", io.ErrUnexpectedEOF, LLMErrorNetwork, true},
+		{"bad key", &openai.APIError{HTTPStatusCode: 401}, LLMErrorAuth, false},
+		{"out of quota", &openai.APIError{HTTPStatusCode: 429, Code: "insufficient_quota"}, LLMErrorQuota, false},
+		{"invalid request", &openai.APIError{HTTPStatusCode: 400}, LLMErrorInvalidRequest, false},
+		{"cancelled", fmt.Errorf("call: %w", context.Canceled), LLMErrorCanceled, false},
+		{"already classified", fmt.Errorf("part 1: %w", server), LLMErrorServer, true},
+		{"unknown", errors.New("something else"), LLMErrorUnknown, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyLLMError(tt.err, 0)
+			if got.Kind != tt.want || got.Retryable() != tt.wantRetry {
+				t.Errorf("kind %s, retryable %v, want %s, %v", got.Kind, got.Retryable(), tt.want, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+		wantOK bool
+	}{
+		{"none", http.Header{}, 0, false},
+		{"seconds", http.Header{"Retry-After": {"20"}}, 20 * time.Second, true},
+		{"milliseconds first", http.Header{"Retry-After": {"20"}, "Retry-After-Ms": {"1500"}}, 1500 * time.Millisecond, true},
+		{"date in the past", http.Header{"Retry-After": {"Wed, 21 Oct 2015 07:28:00 GMT"}}, 0, true},
+		{"garbage", http.Header{"Retry-After": {"soon"}}, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.header)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: %s, %v, want %s, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// flakyProvider fails its calls with errs in turn, and answers once they run out or for a nil error
+type flakyProvider struct {
+	*fakeProvider
+	mu    sync.Mutex
+	errs  []error
+	tries int
+}
+
+func (p *flakyProvider) Complete(ctx context.Context, req LLMRequest) (LLMResponse, error) {
+	p.mu.Lock()
+	var err error
+	if p.tries < len(p.errs) {
+		err = p.errs[p.tries]
+	}
+	p.tries++
+	p.mu.Unlock()
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	return p.fakeProvider.Complete(ctx, req)
+}
+
+func TestRetryingProvider(t *testing.T) {
+	server := &LLMError{Kind: LLMErrorServer, StatusCode: 500, Err: errors.New("internal error")}
+	rateLimited := &LLMError{Kind: LLMErrorRateLimited, StatusCode: 429, RetryAfter: 30 * time.Second, Err: errors.New("slow down")}
+	auth := &LLMError{Kind: LLMErrorAuth, StatusCode: 401, Err: errors.New("bad key")}
+
+	tests := []struct {
+		name        string
+		errs        []error
+		maxAttempts int
+		wantTries   int
+		wantErr     error
+		// wantSleeps are the longest each wait may be; waits are at least 1ns, or exactly
+		// the Retry-After when it's longer than the backoff
+		wantSleeps []time.Duration
+		exact      bool
+	}{
+		{"success", nil, 3, 1, nil, nil, false},
+		{"retried until it works", []error{server, server}, 3, 3, nil, []time.Duration{time.Second, 2 * time.Second}, false},
+		{"backoff is capped", []error{server, server, server, server}, 5, 5, nil, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second}, false},
+		{"gives up after the attempts", []error{server, server, server}, 3, 3, server, []time.Duration{time.Second, 2 * time.Second}, false},
+		{"Retry-After is respected", []error{rateLimited}, 3, 2, nil, []time.Duration{30 * time.Second}, true},
+		{"not retryable", []error{auth}, 3, 1, auth, nil, false},
+		{"cancelled", []error{context.Canceled}, 3, 1, context.Canceled, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaky := &flakyProvider{fakeProvider: newFakeProvider(), errs: tt.errs}
+			p := newRetryingProvider(flaky, tt.maxAttempts, 0)
+			p.maxDelay = 4 * time.Second
+			var sleeps []time.Duration
+			p.sleep = func(ctx context.Context, d time.Duration) error {
+				sleeps = append(sleeps, d)
+				return nil
+			}
+
+			_, err := p.Complete(context.Background(), LLMRequest{Model: "local-model", Prompt: "gm"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if flaky.tries != tt.wantTries {
+				t.Errorf("%d tries, want %d", flaky.tries, tt.wantTries)
+			}
+			if len(sleeps) != len(tt.wantSleeps) {
+				t.Fatalf("waited %v, want %d waits", sleeps, len(tt.wantSleeps))
+			}
+			for i, limit := range tt.wantSleeps {
+				if sleeps[i] <= 0 || sleeps[i] > limit || (tt.exact && sleeps[i] != limit) {
+					t.Errorf("wait %d was %s, want at most %s", i+1, sleeps[i], limit)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryingProviderErrorBudget(t *testing.T) {
+	server := &LLMError{Kind: LLMErrorServer, StatusCode: 500, Err: errors.New("internal error")}
+	// Every try fails: two attempts a call, and the run stops at the second failed call
+	flaky := &flakyProvider{fakeProvider: newFakeProvider(), errs: []error{server, server, server, server, server}}
+	p := newRetryingProvider(flaky, 2, 2)
+	p.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	req := LLMRequest{Model: "local-model", Prompt: "gm"}
+
+	if _, err := p.Complete(context.Background(), req); !errors.Is(err, server) || isRunFatal(err) {
+		t.Fatalf("first call: error = %v, want the server error without ending the run", err)
+	}
+	_, err := p.Complete(context.Background(), req)
+	if !isRunFatal(err) || !errors.Is(err, errLLMErrorBudgetExhausted) || !errors.Is(err, server) {
+		t.Fatalf("second call: error = %v, want the budget exhausted by the server error", err)
+	}
+	if _, err := p.Complete(context.Background(), req); !errors.Is(err, errLLMErrorBudgetExhausted) {
+		t.Fatalf("third call: error = %v, want the budget exhausted", err)
+	}
+	if flaky.tries != 4 {
+		t.Errorf("%d tries, want 4, and none once the budget was exhausted", flaky.tries)
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want the wait cancelled", err)
+	}
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestRetryAfterFromServer(t *testing.T) {
+	// The server rate limits the first request, and answers the second
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"error": {"message": "Rate limit reached", "type": "requests", "code": "rate_limit_exceeded"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id": "1", "object": "chat.completion", "model": "local-model",
+			"choices": [{"index": 0, "message": {"role": "assistant", "content": "gm"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4}}`)
+	}))
+	defer server.Close()
+
+	p := newRetryingProvider(newOpenAICompatibleProvider(server.URL, "test"), 3, 0)
+	var sleeps []time.Duration
+	p.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	response, err := p.Complete(context.Background(), LLMRequest{Model: "local-model", Prompt: "gm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Content != "gm" || response.Usage.PromptTokens != 3 {
+		t.Errorf("response = %+v", response)
+	}
+	if len(sleeps) != 1 || sleeps[0] != 7*time.Second {
+		t.Errorf("waited %v, want the 7s the server asked for", sleeps)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// throttledProvider wraps an LLMProvider to cap how many requests are in flight at once,
+// and to space requests evenly so that no more than requestsPerMinute start each minute.
+// One throttledProvider is shared by all the workers of a run.
+type throttledProvider struct {
+	LLMProvider
+	slots    chan struct{}
+	interval time.Duration // 0 means no rate limit
+
+	mu   sync.Mutex
+	next time.Time // when the next request may start
+}
+
+func newThrottledProvider(provider LLMProvider, concurrency int, requestsPerMinute int) *throttledProvider {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var interval time.Duration
+	if requestsPerMinute > 0 {
+		interval = time.Minute / time.Duration(requestsPerMinute)
+	}
+	return &throttledProvider{
+		LLMProvider: provider,
+		slots:       make(chan struct{}, concurrency),
+		interval:    interval,
+	}
+}
+
+// acquire waits for a free slot and for the rate limit, and returns the function that frees the slot
+func (p *throttledProvider) acquire(ctx context.Context) (func(), error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case p.slots <- struct{}{}:
+	}
+	release := func() { <-p.slots }
+
+	if p.interval > 0 {
+		p.mu.Lock()
+		now := time.Now()
+		start := p.next
+		if start.Before(now) {
+			start = now
+		}
+		p.next = start.Add(p.interval)
+		p.mu.Unlock()
+
+		if wait := time.Until(start); wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				release()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	return release, nil
+}
+
+func (p *throttledProvider) Complete(ctx context.Context, req LLMRequest) (LLMResponse, error) {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	defer release()
+	return p.LLMProvider.Complete(ctx, req)
+}
+
+func (p *throttledProvider) CompleteJSON(ctx context.Context, req LLMRequest, schema openai.ChatCompletionResponseFormatJSONSchema) (LLMResponse, error) {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	defer release()
+	return p.LLMProvider.CompleteJSON(ctx, req, schema)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// errCostBudgetExceeded is returned for every LLM call once the run has spent its budget
+var errCostBudgetExceeded = errors.New("LLM cost budget exceeded")
+
+// isRunFatal tells whether an LLM error should stop the whole run, rather than only
+// degrade the section being written
+func isRunFatal(err error) bool {
+	return errors.Is(err, errLLMErrorBudgetExhausted) || errors.Is(err, errCostBudgetExceeded)
+}
+
+// ModelPrice is what a model costs, in USD per million tokens
+type ModelPrice struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
+
+// defaultModelPrices are from https://openai.com/api/pricing/; a prices file overrides them
+var defaultModelPrices = map[string]ModelPrice{
+	GPT3_5_turbo:   {InputPerMillion: 0.50, OutputPerMillion: 1.50},
+	GPT4_o:         {InputPerMillion: 5.00, OutputPerMillion: 15.00},
+	GPT4_turbo:     {InputPerMillion: 10.00, OutputPerMillion: 30.00},
+	GPT4_o_mini:    {InputPerMillion: 0.15, OutputPerMillion: 0.60},
+	"gpt-4o":       {InputPerMillion: 2.50, OutputPerMillion: 10.00},
+	"gpt-4.1":      {InputPerMillion: 2.00, OutputPerMillion: 8.00},
+	"gpt-4.1-mini": {InputPerMillion: 0.40, OutputPerMillion: 1.60},
+	"gpt-4.1-nano": {InputPerMillion: 0.10, OutputPerMillion: 0.40},
+}
+
+// loadModelPrices returns the default prices, overridden by the JSON object in path if
+// there is one, e.g. {"llama3.1": {"input_per_million": 0, "output_per_million": 0}}
+func loadModelPrices(path string) (map[string]ModelPrice, error) {
+	prices := make(map[string]ModelPrice)
+	for model, price := range defaultModelPrices {
+		prices[model] = price
+	}
+	if path == "" {
+		return prices, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prices file: %v", err)
+	}
+	var overrides map[string]ModelPrice
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse prices file %s: %v", path, err)
+	}
+	for model, price := range overrides {
+		prices[model] = price
+	}
+	return prices, nil
+}
+
+// llmStage says what an LLM call was made for, so its cost can be attributed
+type llmStage struct {
+	Stage   string // e.g. "account_summary" or "overall_summary"
+	Account string
+	Date    string
+}
+
+type llmStageKey struct{}
+
+func withLLMStage(ctx context.Context, stage llmStage) context.Context {
+	return context.WithValue(ctx, llmStageKey{}, stage)
+}
+
+func llmStageFrom(ctx context.Context) llmStage {
+	stage, _ := ctx.Value(llmStageKey{}).(llmStage)
+	if stage.Stage == "" {
+		stage.Stage = "other"
+	}
+	return stage
+}
+
+type usageRecord struct {
+	stage  llmStage
+	model  string
+	usage  LLMUsage
+	cost   float64
+	cached bool
+}
+
+// reservedCompletionTokens is how many answer tokens a call is assumed to cost while it's in
+// flight. Summaries and verdicts are shorter.
+const reservedCompletionTokens = 1024
+
+// usageTracker wraps an LLMProvider to record the tokens and cost of every call, attributed
+// to the stage in the call's context, and to refuse calls once maxCost has been spent. Calls
+// in flight count toward maxCost with an estimate of their cost, so that concurrent workers
+// don't all start calls the budget can't cover.
+type usageTracker struct {
+	LLMProvider
+	prices  map[string]ModelPrice
+	maxCost float64 // USD; 0 means no cap
+
+	mu       sync.Mutex
+	records  []usageRecord
+	spent    float64
+	reserved float64 // estimated cost of the calls in flight
+	unpriced map[string]bool
+}
+
+func newUsageTracker(provider LLMProvider, prices map[string]ModelPrice, maxCost float64) *usageTracker {
+	return &usageTracker{LLMProvider: provider, prices: prices, maxCost: maxCost, unpriced: make(map[string]bool)}
+}
+
+func (t *usageTracker) Complete(ctx context.Context, req LLMRequest) (LLMResponse, error) {
+	reservation, err := t.reserve(req)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	response, err := t.LLMProvider.Complete(ctx, req)
+	t.record(ctx, req, response, err, reservation)
+	return response, err
+}
+
+func (t *usageTracker) CompleteJSON(ctx context.Context, req LLMRequest, schema openai.ChatCompletionResponseFormatJSONSchema) (LLMResponse, error) {
+	reservation, err := t.reserve(req)
+	if err != nil {
+		return LLMResponse{}, err
+	}
+	response, err := t.LLMProvider.CompleteJSON(ctx, req, schema)
+	t.record(ctx, req, response, err, reservation)
+	return response, err
+}
+
+// reserve refuses the call if what was spent and what the calls in flight may cost reach
+// maxCost, and otherwise sets aside an estimate of the call's cost until record
+func (t *usageTracker) reserve(req LLMRequest) (float64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.maxCost <= 0 {
+		return 0, nil
+	}
+	if t.spent+t.reserved >= t.maxCost {
+		return 0, fmt.Errorf("%w: spent $%.4f of $%.4f, with $%.4f more in flight", errCostBudgetExceeded, t.spent, t.maxCost, t.reserved)
+	}
+	price := t.prices[req.Model]
+	estimate := (float64(estimateTokens(req.Prompt))*price.InputPerMillion +
+		float64(reservedCompletionTokens)*price.OutputPerMillion) / 1e6
+	t.reserved += estimate
+	return estimate, nil
+}
+
+// record releases the call's reservation, and if it succeeded, adds what it cost
+func (t *usageTracker) record(ctx context.Context, req LLMRequest, response LLMResponse, err error, reservation float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.reserved -= reservation
+	if err != nil {
+		return
+	}
+	cost := 0.0
+	if !response.Cached {
+		price, ok := t.prices[req.Model]
+		if !ok && !t.unpriced[req.Model] {
+			fmt.Printf("Warning: no price for model %s, its cost is counted as 0\n", req.Model)
+			t.unpriced[req.Model] = true
+		}
+		cost = (float64(response.Usage.PromptTokens)*price.InputPerMillion +
+			float64(response.Usage.CompletionTokens)*price.OutputPerMillion) / 1e6
+	}
+	t.spent += cost
+	t.records = append(t.records, usageRecord{
+		stage:  llmStageFrom(ctx),
+		model:  req.Model,
+		usage:  response.Usage,
+		cost:   cost,
+		cached: response.Cached,
+	})
+}
+
+// reset forgets the calls recorded so far, at the start of a run
+func (t *usageTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = nil
+	t.spent = 0
+}
+
+// CostReport is what the LLM calls of a run cost, broken down by what they were made for.
+// Cached calls cost nothing and aren't included in the tokens and breakdowns.
+type CostReport struct {
+	Currency         string     `json:"currency"`
+	TotalCost        float64    `json:"total_cost"`
+	BudgetCap        float64    `json:"budget_cap,omitempty"`
+	Calls            int        `json:"calls"`
+	CachedCalls      int        `json:"cached_calls"`
+	PromptTokens     int        `json:"prompt_tokens"`
+	CompletionTokens int        `json:"completion_tokens"`
+	UnpricedModels   []string   `json:"unpriced_models,omitempty"`
+	ByStage          []CostLine `json:"by_stage"`
+	ByModel          []CostLine `json:"by_model"`
+	ByDay            []CostLine `json:"by_day,omitempty"`
+	ByAccount        []CostLine `json:"by_account,omitempty"`
+}
+
+type CostLine struct {
+	Key              string  `json:"key"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// costReport summarizes the calls recorded since the last reset
+func (t *usageTracker) costReport() *CostReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := &CostReport{Currency: "USD", BudgetCap: t.maxCost}
+	byStage := make(map[string]*CostLine)
+	byModel := make(map[string]*CostLine)
+	byDay := make(map[string]*CostLine)
+	byAccount := make(map[string]*CostLine)
+	add := func(lines map[string]*CostLine, key string, r usageRecord) {
+		if key == "" {
+			return
+		}
+		line, ok := lines[key]
+		if !ok {
+			line = &CostLine{Key: key}
+			lines[key] = line
+		}
+		line.Calls++
+		line.PromptTokens += r.usage.PromptTokens
+		line.CompletionTokens += r.usage.CompletionTokens
+		line.Cost += r.cost
+	}
+
+	for _, r := range t.records {
+		if r.cached {
+			report.CachedCalls++
+			continue
+		}
+		report.Calls++
+		report.TotalCost += r.cost
+		report.PromptTokens += r.usage.PromptTokens
+		report.CompletionTokens += r.usage.CompletionTokens
+		add(byStage, r.stage.Stage, r)
+		add(byModel, r.model, r)
+		add(byDay, r.stage.Date, r)
+		add(byAccount, r.stage.Account, r)
+	}
+	for model := range t.unpriced {
+		report.UnpricedModels = append(report.UnpricedModels, model)
+	}
+	sort.Strings(report.UnpricedModels)
+
+	report.ByStage = sortedCostLines(byStage)
+	report.ByModel = sortedCostLines(byModel)
+	report.ByDay = sortedCostLines(byDay)
+	report.ByAccount = sortedCostLines(byAccount)
+	return report
+}
+
+// sortedCostLines orders lines by key, so that days come out in date order
+func sortedCostLines(lines map[string]*CostLine) []CostLine {
+	sorted := make([]CostLine, 0, len(lines))
+	for _, line := range lines {
+		sorted = append(sorted, *line)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	return sorted
+}
+
+func (r *CostReport) String() string {
+	return fmt.Sprintf("LLM cost: $%.4f for %d calls (%d prompt + %d completion tokens), %d calls served from cache",
+		r.TotalCost, r.Calls, r.PromptTokens, r.CompletionTokens, r.CachedCalls)
+}
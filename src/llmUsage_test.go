@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testPrices make a call of the test prompt, 10 tokens by estimateTokens, cost $10
+var testPrices = map[string]ModelPrice{"local-model": {InputPerMillion: 1e6}}
+
+var testPrompt = LLMRequest{Model: "local-model", Prompt: strings.Repeat("x", 30)}
+
+func TestUsageTrackerBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxCost   float64
+		calls     int
+		wantCalls int // that reach the provider
+		wantSpent float64
+	}{
+		{"no cap", 0, 5, 5, 50},
+		{"below the cap", 100, 5, 5, 50},
+		{"stops at the cap", 25, 5, 3, 30},
+		{"exactly the cap", 20, 5, 2, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeProvider()
+			tracker := newUsageTracker(fake, testPrices, tt.maxCost)
+			for i := 0; i < tt.calls; i++ {
+				_, err := tracker.Complete(context.Background(), testPrompt)
+				if wantErr := i >= tt.wantCalls; (err != nil) != wantErr || (wantErr && !isRunFatal(err)) {
+					t.Errorf("call %d: error = %v, want the budget exceeded: %v", i+1, err, wantErr)
+				}
+			}
+			if calls := len(fake.Calls()); calls != tt.wantCalls {
+				t.Errorf("%d calls reached the provider, want %d", calls, tt.wantCalls)
+			}
+			if report := tracker.costReport(); report.TotalCost != tt.wantSpent || report.Calls != tt.wantCalls {
+				t.Errorf("cost report: $%.2f for %d calls, want $%.2f for %d", report.TotalCost, report.Calls, tt.wantSpent, tt.wantCalls)
+			}
+		})
+	}
+}
+
+// gatedProvider holds every call until release is closed, telling entered that it started
+type gatedProvider struct {
+	*fakeProvider
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (p *gatedProvider) Complete(ctx context.Context, req LLMRequest) (LLMResponse, error) {
+	p.entered <- struct{}{}
+	<-p.release
+	return p.fakeProvider.Complete(ctx, req)
+}
+
+func TestUsageTrackerBudgetWithConcurrentCalls(t *testing.T) {
+	const workers = 8
+	gated := &gatedProvider{fakeProvider: newFakeProvider(), entered: make(chan struct{}, workers), release: make(chan struct{})}
+	tracker := newUsageTracker(gated, testPrices, 25)
+
+	refused := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tracker.Complete(context.Background(), testPrompt); err != nil {
+				refused <- err
+			}
+		}()
+	}
+	// Every worker has either started its call or been refused before any call finishes
+	started, refusals := 0, 0
+	for started+refusals < workers {
+		select {
+		case <-gated.entered:
+			started++
+		case err := <-refused:
+			refusals++
+			if !errors.Is(err, errCostBudgetExceeded) {
+				t.Errorf("refused with %v, want the budget exceeded", err)
+			}
+		}
+	}
+	close(gated.release)
+	wg.Wait()
+
+	// $25 covers two calls in flight and the start of a third
+	if started != 3 {
+		t.Errorf("%d calls started, want 3", started)
+	}
+	if report := tracker.costReport(); report.TotalCost != 30 {
+		t.Errorf("spent $%.2f, want $30", report.TotalCost)
+	}
+	if tracker.reserved != 0 {
+		t.Errorf("$%.2f is still reserved after every call finished", tracker.reserved)
+	}
+}
+
+func TestUsageTrackerReleasesFailedCalls(t *testing.T) {
+	fake := newFakeProvider().withFailure(0, &LLMError{Kind: LLMErrorServer, Err: errors.New("503")})
+	tracker := newUsageTracker(fake, testPrices, 25)
+	for i := 0; i < 5; i++ {
+		if _, err := tracker.Complete(context.Background(), testPrompt); isRunFatal(err) {
+			t.Fatalf("call %d: %v, want failed calls not to use up the budget", i+1, err)
+		}
+	}
+	if tracker.reserved != 0 || tracker.spent != 0 {
+		t.Errorf("reserved $%.2f and spent $%.2f, want nothing", tracker.reserved, tracker.spent)
+	}
+}
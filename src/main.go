@@ -1,28 +1,75 @@
 package main
 
 import (
-        "fmt"
-        "os"
-        "time"
+	"fmt"
+	"os"
 )
 
-func main() {
-        // Set the accounts list filename
-        accountsList := "ai-og"
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: %s <command> [flags]
 
-        // Get the current time and calculate the start date for the report (last 7 days)
-        endDate := time.Now()
-        startDate := endDate.AddDate(0, 0, -7)
+Commands:
+  report       Generate a multi-day report and save it (default)
+  daily        Generate a report for a single day
+  fetch        Fetch new tweets from a timeline source into the store
+  accounts     Show the accounts in an accounts list
+  search       Search tweets by text
+  dump         Copy tweets into a directory of JSONL files for offline use
+  serve        Keep fetching tweets and making daily and weekly reports on a schedule
+  graph        Export the graph of mentions, replies, quotes and retweets between accounts
+  discover     Rank accounts the list interacts with as candidates for an accounts list
+  promote      Add the approved candidates of a discover run to an accounts list
+  classify     Score how agent-like accounts post, or train the scoring on labeled lists
+  notify-test  Send a test alert to the configured alert sinks
 
-        // Create an instance of App
-        app := &App{}
+Run '%s <command> -h' for the flags of each command.
+`, os.Args[0], os.Args[0])
+}
 
-        // Generate and save reports for the specified accounts list from the start date for 7 days
-        if err := app.GenerateReports(accountsList, startDate, 7); err != nil {
-                fmt.Printf("Error generating reports: %v\n", err)
-                os.Exit(1)
-        }
+func main() {
+	// Without a command, keep the old behaviour: a 7-day report for ai-og
+	command, args := "report", []string{}
+	if len(os.Args) > 1 {
+		command, args = os.Args[1], os.Args[2:]
+	}
 
-        fmt.Println("Weekly report generation completed successfully.")
-}
+	var err error
+	switch command {
+	case "report":
+		err = runReport(args)
+	case "daily":
+		err = runDaily(args)
+	case "fetch":
+		err = runFetch(args)
+	case "accounts":
+		err = runAccounts(args)
+	case "search":
+		err = runSearch(args)
+	case "dump":
+		err = runDump(args)
+	case "serve":
+		err = runServe(args)
+	case "graph":
+		err = runGraph(args)
+	case "discover":
+		err = runDiscover(args)
+	case "promote":
+		err = runPromote(args)
+	case "classify":
+		err = runClassify(args)
+	case "notify-test":
+		err = runNotifyTest(args)
+	case "help", "-h", "-help", "--help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n", command)
+		usage()
+		os.Exit(2)
+	}
 
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
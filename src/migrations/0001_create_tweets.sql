@@ -0,0 +1,8 @@
+-- The original schema, from the README
+CREATE TABLE IF NOT EXISTS tweets0x001 (
+    id SERIAL PRIMARY KEY,
+    tweet_id TEXT NOT NULL UNIQUE,
+    tweet_text TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    username TEXT NOT NULL
+);
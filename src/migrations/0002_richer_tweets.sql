@@ -0,0 +1,21 @@
+-- References, metadata, metrics and the raw payload of each tweet.
+-- Every column is nullable, so rows from before this migration stay valid.
+ALTER TABLE tweets0x001
+    ADD COLUMN IF NOT EXISTS conversation_id TEXT,
+    ADD COLUMN IF NOT EXISTS in_reply_to_id TEXT,
+    ADD COLUMN IF NOT EXISTS in_reply_to_username TEXT,
+    ADD COLUMN IF NOT EXISTS quoted_id TEXT,
+    ADD COLUMN IF NOT EXISTS quoted_username TEXT,
+    ADD COLUMN IF NOT EXISTS retweeted_id TEXT,
+    ADD COLUMN IF NOT EXISTS retweeted_username TEXT,
+    ADD COLUMN IF NOT EXISTS lang TEXT,
+    ADD COLUMN IF NOT EXISTS urls TEXT[],
+    ADD COLUMN IF NOT EXISTS media TEXT[],
+    ADD COLUMN IF NOT EXISTS like_count INTEGER,
+    ADD COLUMN IF NOT EXISTS retweet_count INTEGER,
+    ADD COLUMN IF NOT EXISTS reply_count INTEGER,
+    ADD COLUMN IF NOT EXISTS quote_count INTEGER,
+    ADD COLUMN IF NOT EXISTS fetched_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS raw JSONB;
+
+CREATE INDEX IF NOT EXISTS tweets0x001_conversation_id_idx ON tweets0x001 (conversation_id);
@@ -0,0 +1,8 @@
+-- Daily reports of days that are over, reused by later runs.
+CREATE TABLE IF NOT EXISTS daily_reports (
+    accounts_list TEXT NOT NULL,
+    report_date DATE NOT NULL,
+    report JSONB NOT NULL,
+    generated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (accounts_list, report_date)
+);
@@ -0,0 +1,4 @@
+-- created_at was a TIMESTAMP holding UTC wall-clock times, so comparing it against day
+-- boundaries depended on the session's zone. As a TIMESTAMPTZ it's an absolute instant.
+ALTER TABLE tweets0x001
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
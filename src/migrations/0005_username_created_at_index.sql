@@ -0,0 +1,5 @@
+-- Reports select the tweets of a list of accounts over a window, newest first, so an
+-- index on both lets postgres read only those rows instead of the whole window.
+-- Transactional migrations can't build it CONCURRENTLY; on a large table, create it by
+-- hand with CREATE INDEX CONCURRENTLY before upgrading, and this is a no-op.
+CREATE INDEX IF NOT EXISTS tweets0x001_username_created_at_idx ON tweets0x001 (username, created_at DESC);
@@ -0,0 +1,23 @@
+-- Interactions between accounts, extracted from the tweets of an accounts list per day.
+-- interaction_days records which days were extracted, since a day may have no edges.
+CREATE TABLE IF NOT EXISTS interaction_days (
+    accounts_list TEXT NOT NULL,
+    day DATE NOT NULL,
+    timezone TEXT NOT NULL,
+    generated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (accounts_list, day)
+);
+
+CREATE TABLE IF NOT EXISTS interactions (
+    accounts_list TEXT NOT NULL,
+    day DATE NOT NULL,
+    source TEXT NOT NULL,
+    target TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    weight INTEGER NOT NULL,
+    PRIMARY KEY (accounts_list, day, source, target, kind),
+    FOREIGN KEY (accounts_list, day) REFERENCES interaction_days ON DELETE CASCADE
+);
+
+-- Who interacts with an account, for looking beyond the accounts we observe
+CREATE INDEX IF NOT EXISTS interactions_target_day_idx ON interactions (target, day);
@@ -0,0 +1,3 @@
+-- The accounts a day's interactions were extracted from, so that they're extracted again
+-- when the list changes. Days stored before have none, and are extracted again once.
+ALTER TABLE interaction_days ADD COLUMN IF NOT EXISTS fingerprint TEXT NOT NULL DEFAULT '';
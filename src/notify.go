@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// alertDedupeFor is how long an alert isn't sent again after it was sent
+const alertDedupeFor = 7 * 24 * time.Hour
+
+// AlertSink is somewhere alerts are delivered to
+type AlertSink interface {
+	Name() string
+	Send(ctx context.Context, alerts []Alert) error
+}
+
+// Notifier delivers alerts to its sinks. Alerts below minSeverity are dropped, alerts
+// already sent to a sink in the last alertDedupeFor aren't sent to it again, and during quiet
+// hours all but critical alerts are held until the quiet hours end. Alerts a sink fails to take
+// are held too, and retried for that sink only. What was sent and held is kept in a state
+// file, so this works across runs.
+type Notifier struct {
+	sinks       []AlertSink
+	minSeverity string
+	quiet       *quietHours // nil when there are no quiet hours
+	location    *time.Location
+	statePath   string
+
+	mu sync.Mutex
+}
+
+// notifierState is what the Notifier has sent, and holds back
+type notifierState struct {
+	// When each alert was last sent to each sink, by sink name and then alertKey
+	Sent map[string]map[string]time.Time `json:"sent_by_sink"`
+	Held []Alert                         `json:"held,omitempty"`
+}
+
+// sentToAll tells whether every sink has been sent the alert with key
+func (n *Notifier) sentToAll(state notifierState, key string) bool {
+	for _, sink := range n.sinks {
+		if _, sent := state.Sent[sink.Name()][key]; !sent {
+			return false
+		}
+	}
+	return true
+}
+
+// newNotifier creates the Notifier of the sinks configured in the environment:
+// ALERT_WEBHOOK_URL, ALERT_SLACK_WEBHOOK_URL and ALERT_SMTP_*, plus the log in cfg.AlertLog
+func newNotifier(cfg Config, location *time.Location) (*Notifier, error) {
+	if _, ok := severityRank[cfg.AlertMinSeverity]; !ok {
+		return nil, fmt.Errorf("unknown alert severity %q (want info, warning or critical)", cfg.AlertMinSeverity)
+	}
+	notifier := &Notifier{
+		minSeverity: cfg.AlertMinSeverity,
+		location:    location,
+		statePath:   cfg.AlertStateFile,
+	}
+	if cfg.QuietHours != "" {
+		quiet, err := parseQuietHours(cfg.QuietHours)
+		if err != nil {
+			return nil, err
+		}
+		notifier.quiet = &quiet
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	if cfg.AlertLog != "" {
+		notifier.sinks = append(notifier.sinks, &fileAlertSink{path: cfg.AlertLog})
+	}
+	if url := os.Getenv("ALERT_WEBHOOK_URL"); url != "" {
+		notifier.sinks = append(notifier.sinks, &webhookAlertSink{url: url, client: client})
+	}
+	if url := os.Getenv("ALERT_SLACK_WEBHOOK_URL"); url != "" {
+		notifier.sinks = append(notifier.sinks, &slackAlertSink{url: url, client: client})
+	}
+	if addr := os.Getenv("ALERT_SMTP_ADDR"); addr != "" {
+		sink := &smtpAlertSink{
+			addr:     addr,
+			username: os.Getenv("ALERT_SMTP_USERNAME"),
+			password: os.Getenv("ALERT_SMTP_PASSWORD"),
+			from:     os.Getenv("ALERT_SMTP_FROM"),
+			to:       splitList(os.Getenv("ALERT_SMTP_TO")),
+		}
+		if sink.from == "" || len(sink.to) == 0 {
+			return nil, fmt.Errorf("ALERT_SMTP_ADDR needs ALERT_SMTP_FROM and ALERT_SMTP_TO")
+		}
+		notifier.sinks = append(notifier.sinks, sink)
+	}
+	return notifier, nil
+}
+
+// notifyAlerts sends alerts to the configured sinks. Delivery failures are reported but
+// don't fail the run, since the alerts are also in the report.
+func (a *App) notifyAlerts(ctx context.Context, alerts []Alert) {
+	if a.notifier == nil {
+		return
+	}
+	if err := a.notifier.Notify(ctx, alerts, time.Now()); err != nil {
+		fmt.Printf("Warning: failed to deliver alerts: %v\n", err)
+	}
+}
+
+// alertKey identifies an alert for deduplication. Whole-window alerts are told apart by their
+// window, so that the scheduler's one-day checks don't hold back those of a weekly report.
+func alertKey(alert Alert) string {
+	key := alert.Kind + "|" + alert.Account + "|" + alert.Date
+	if alert.Window != "" {
+		key += "|" + alert.Window
+	}
+	return key
+}
+
+// Notify delivers the alerts that are due at now. Alerts that a sink failed to take are held
+// and retried by the next call, for the sinks that haven't got them; the errors of the sinks
+// that failed are returned.
+func (n *Notifier) Notify(ctx context.Context, alerts []Alert, now time.Time) error {
+	if len(n.sinks) == 0 {
+		return nil
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	state, err := n.loadState()
+	if err != nil {
+		return err
+	}
+	for sink, sent := range state.Sent {
+		for key, sentAt := range sent {
+			if now.Sub(sentAt) >= alertDedupeFor {
+				delete(sent, key)
+			}
+		}
+		if len(sent) == 0 {
+			delete(state.Sent, sink)
+		}
+	}
+
+	pending := make(map[string]bool)
+	for _, alert := range state.Held {
+		pending[alertKey(alert)] = true
+	}
+	quiet := n.quiet != nil && n.quiet.contains(now.In(n.location))
+	var due []Alert
+	if !quiet {
+		due, state.Held = state.Held, nil
+	}
+	for _, alert := range alerts {
+		key := alertKey(alert)
+		if severityRank[alert.Severity] < severityRank[n.minSeverity] || pending[key] {
+			continue
+		}
+		if n.sentToAll(state, key) {
+			continue
+		}
+		pending[key] = true
+		if quiet && alert.Severity != severityCritical {
+			state.Held = append(state.Held, alert)
+		} else {
+			due = append(due, alert)
+		}
+	}
+
+	// Each sink gets the due alerts it hasn't been sent. A sink that works, like the local
+	// log, doesn't mark alerts as sent for one that fails.
+	var errs []error
+	failed := make(map[string]bool)
+	for _, sink := range n.sinks {
+		var unsent []Alert
+		for _, alert := range due {
+			if _, sent := state.Sent[sink.Name()][alertKey(alert)]; !sent {
+				unsent = append(unsent, alert)
+			}
+		}
+		if len(unsent) == 0 {
+			continue
+		}
+		if err := sink.Send(ctx, unsent); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", sink.Name(), err))
+			for _, alert := range unsent {
+				failed[alertKey(alert)] = true
+			}
+			continue
+		}
+		if state.Sent[sink.Name()] == nil {
+			state.Sent[sink.Name()] = make(map[string]time.Time)
+		}
+		for _, alert := range unsent {
+			state.Sent[sink.Name()][alertKey(alert)] = now
+		}
+		fmt.Printf("Sent %s to %s\n", alertsTitle(unsent), sink.Name())
+	}
+	for _, alert := range due {
+		if failed[alertKey(alert)] {
+			state.Held = append(state.Held, alert)
+		}
+	}
+	if len(state.Held) > 0 && quiet {
+		fmt.Printf("Holding %d alerts until the quiet hours end at %s\n", len(state.Held), n.quiet.end)
+	}
+
+	if err := n.saveState(state); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Flush delivers the alerts held during quiet hours once they are over, and retries those
+// a sink failed to take
+func (n *Notifier) Flush(ctx context.Context, now time.Time) error {
+	if len(n.sinks) == 0 {
+		return nil
+	}
+	n.mu.Lock()
+	state, err := n.loadState()
+	n.mu.Unlock()
+	if err != nil || len(state.Held) == 0 {
+		return err
+	}
+	return n.Notify(ctx, nil, now)
+}
+
+// Test sends alerts to every sink, skipping severity, deduplication and quiet hours
+func (n *Notifier) Test(ctx context.Context, alerts []Alert) error {
+	if len(n.sinks) == 0 {
+		return fmt.Errorf("no alert sinks configured")
+	}
+	var errs []error
+	for _, sink := range n.sinks {
+		if err := sink.Send(ctx, alerts); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", sink.Name(), err))
+			continue
+		}
+		fmt.Printf("Sent test alert to %s\n", sink.Name())
+	}
+	return errors.Join(errs...)
+}
+
+func (n *Notifier) loadState() (notifierState, error) {
+	state := notifierState{Sent: make(map[string]map[string]time.Time)}
+	data, err := os.ReadFile(n.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to read alert state: %v", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("failed to parse alert state %s: %v", n.statePath, err)
+	}
+	if state.Sent == nil {
+		state.Sent = make(map[string]map[string]time.Time)
+	}
+	return state, nil
+}
+
+// saveState writes the state through a temporary file, so a crash never leaves half of it
+func (n *Notifier) saveState(state notifierState) error {
+	if err := os.MkdirAll(filepath.Dir(n.statePath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert state: %v", err)
+	}
+	tmp := n.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write alert state: %v", err)
+	}
+	if err := os.Rename(tmp, n.statePath); err != nil {
+		return fmt.Errorf("failed to write alert state: %v", err)
+	}
+	return nil
+}
+
+// quietHours is a daily span of wall-clock time, which may wrap past midnight
+type quietHours struct {
+	start, end string // HH:MM
+}
+
+// parseQuietHours parses a span like "22:00-07:00"
+func parseQuietHours(span string) (quietHours, error) {
+	start, end, ok := strings.Cut(span, "-")
+	if !ok {
+		return quietHours{}, fmt.Errorf("invalid quiet hours %q (want e.g. 22:00-07:00)", span)
+	}
+	quiet := quietHours{start: strings.TrimSpace(start), end: strings.TrimSpace(end)}
+	for _, t := range []string{quiet.start, quiet.end} {
+		if _, err := time.Parse("15:04", t); err != nil || len(t) != 5 {
+			return quietHours{}, fmt.Errorf("invalid quiet hours %q (want e.g. 22:00-07:00)", span)
+		}
+	}
+	return quiet, nil
+}
+
+func (q quietHours) contains(now time.Time) bool {
+	clock := now.Format("15:04")
+	if q.start <= q.end {
+		return clock >= q.start && clock < q.end
+	}
+	return clock >= q.start || clock < q.end
+}
+
+// alertLine is the one-line text of an alert in messages
+func alertLine(alert Alert) string {
+	return fmt.Sprintf("[%s] %s", alert.Severity, alert.Message)
+}
+
+// alertsTitle summarizes alerts, e.g. "3 alerts (1 critical)"
+func alertsTitle(alerts []Alert) string {
+	critical := 0
+	for _, alert := range alerts {
+		if alert.Severity == severityCritical {
+			critical++
+		}
+	}
+	title := fmt.Sprintf("%d alerts", len(alerts))
+	if len(alerts) == 1 {
+		title = "1 alert"
+	}
+	if critical > 0 {
+		title += fmt.Sprintf(" (%d critical)", critical)
+	}
+	return title
+}
+
+// fileAlertSink appends alerts to a local log, one JSON object per line
+type fileAlertSink struct {
+	path string
+}
+
+func (s *fileAlertSink) Name() string {
+	return "log " + s.path
+}
+
+func (s *fileAlertSink) Send(ctx context.Context, alerts []Alert) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create alerts log directory: %v", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open alerts log: %v", err)
+	}
+	sentAt := time.Now().UTC().Format(time.RFC3339)
+	var buf bytes.Buffer
+	for _, alert := range alerts {
+		line, err := json.Marshal(struct {
+			SentAt string `json:"sent_at"`
+			Alert
+		}{sentAt, alert})
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to marshal alert: %v", err)
+		}
+		buf.Write(append(line, '\n'))
+	}
+	// One write per batch, so concurrent runs don't interleave lines
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write alerts log: %v", err)
+	}
+	return file.Close()
+}
+
+// webhookAlertSink posts {"title": ..., "alerts": [...]} as JSON
+type webhookAlertSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *webhookAlertSink) Name() string {
+	return "webhook"
+}
+
+func (s *webhookAlertSink) Send(ctx context.Context, alerts []Alert) error {
+	return postJSON(ctx, s.client, s.url, map[string]any{"title": alertsTitle(alerts), "alerts": alerts})
+}
+
+// slackAlertSink posts a message in the format of Slack incoming webhooks, which
+// Mattermost, Discord's /slack endpoint and others accept too
+type slackAlertSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s *slackAlertSink) Name() string {
+	return "slack"
+}
+
+func (s *slackAlertSink) Send(ctx context.Context, alerts []Alert) error {
+	lines := []string{"*" + alertsTitle(alerts) + "*"}
+	for _, alert := range alerts {
+		lines = append(lines, "• "+slackEscape(alertLine(alert)))
+	}
+	return postJSON(ctx, s.client, s.url, map[string]string{"text": strings.Join(lines, "\n")})
+}
+
+// slackEscape escapes the characters Slack's message format gives a meaning to
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post alerts: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// smtpAlertSink emails alerts. It authenticates with PLAIN when a username is set, which
+// net/smtp only allows over TLS or to localhost.
+type smtpAlertSink struct {
+	addr     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func (s *smtpAlertSink) Name() string {
+	return "smtp " + s.addr
+}
+
+func (s *smtpAlertSink) Send(ctx context.Context, alerts []Alert) error {
+	var auth smtp.Auth
+	if s.username != "" {
+		host, _, _ := strings.Cut(s.addr, ":")
+		auth = smtp.PlainAuth("", s.username, s.password, host)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", s.from)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&body, "Subject: twitter-cli: %s\r\n", alertsTitle(alerts))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, alert := range alerts {
+		body.WriteString(alertLine(alert) + "\r\n")
+	}
+
+	// net/smtp takes no context, so a cancelled run waits for the send to finish
+	if err := smtp.SendMail(s.addr, auth, s.from, s.to, []byte(body.String())); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	return nil
+}
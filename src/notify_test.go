@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testWebhook is a webhook that counts the alerts posted to it, and fails while failing is set
+type testWebhook struct {
+	*httptest.Server
+	failing atomic.Bool
+	alerts  atomic.Int64
+}
+
+func newTestWebhook(t *testing.T) *testWebhook {
+	w := &testWebhook{}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if w.failing.Load() {
+			http.Error(rw, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var payload struct {
+			Alerts []Alert `json:"alerts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.alerts.Add(int64(len(payload.Alerts)))
+	}))
+	t.Cleanup(w.Close)
+	return w
+}
+
+// testSMTPServer speaks just enough SMTP for net/smtp.SendMail, and counts the alert lines
+// of the emails it receives
+type testSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	alerts   int
+}
+
+func newTestSMTPServer(t *testing.T) *testSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSMTPServer{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 end with <CRLF>.<CRLF>")
+			alerts := 0
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				if strings.HasPrefix(line, "[") {
+					alerts++
+				}
+			}
+			s.mu.Lock()
+			s.alerts += alerts
+			s.mu.Unlock()
+			reply("250 queued")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *testSMTPServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.alerts
+}
+
+func countLines(t *testing.T, path string) int {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestNotifier(t *testing.T) {
+	warning := Alert{Kind: "burst", Severity: severityWarning, Account: "alice", Date: "2025-01-06", Message: "@alice posted 40 tweets"}
+	critical := Alert{Kind: "silence", Severity: severityCritical, Account: "bob", Date: "2025-01-06", Message: "@bob went silent"}
+	other := Alert{Kind: "burst", Severity: severityWarning, Account: "carol", Date: "2025-01-06", Message: "@carol posted 30 tweets"}
+	info := Alert{Kind: "shift", Severity: severityInfo, Account: "alice", Message: "@alice posts at other hours"}
+	// The same schedule shift, found by the scheduler's one-day check and by a weekly report
+	shiftDay := Alert{Kind: alertScheduleShift, Severity: severityWarning, Account: "alice", Window: "2025-01-06", Message: "@alice changed its schedule"}
+	shiftWeek := shiftDay
+	shiftWeek.Window = "2025-01-06 to 2025-01-12"
+	day := func(hour int) time.Time { return time.Date(2025, 1, 7, hour, 0, 0, 0, time.UTC) }
+
+	// A step is a call to Notify, or to Flush if flush is set, and the alerts each sink
+	// should receive from it
+	type step struct {
+		now         time.Time
+		alerts      []Alert
+		flush       bool
+		failWebhook bool
+		wantWebhook int
+		wantSMTP    int
+		wantLog     int
+		wantHeld    int
+		wantErr     bool
+	}
+	tests := []struct {
+		name  string
+		quiet string
+		steps []step
+	}{
+		{"delivery and dedupe", "", []step{
+			{now: day(12), alerts: []Alert{warning, critical}, wantWebhook: 2, wantSMTP: 2, wantLog: 2},
+			{now: day(13), alerts: []Alert{warning, critical}},
+			{now: day(14), alerts: []Alert{warning, critical, other}, wantWebhook: 1, wantSMTP: 1, wantLog: 1},
+			// A week later, the same alert is news again
+			{now: day(12).AddDate(0, 0, 7), alerts: []Alert{warning}, wantWebhook: 1, wantSMTP: 1, wantLog: 1},
+		}},
+		{"window alerts of other windows", "", []step{
+			{now: day(12), alerts: []Alert{shiftDay}, wantWebhook: 1, wantSMTP: 1, wantLog: 1},
+			{now: day(13), alerts: []Alert{shiftWeek, shiftDay}, wantWebhook: 1, wantSMTP: 1, wantLog: 1},
+		}},
+		{"below the minimum severity", "", []step{
+			{now: day(12), alerts: []Alert{info}},
+		}},
+		{"failed sink is retried alone", "", []step{
+			{now: day(12), alerts: []Alert{warning, critical}, failWebhook: true, wantSMTP: 2, wantLog: 2, wantHeld: 2, wantErr: true},
+			{now: day(13), alerts: []Alert{warning}, failWebhook: true, wantHeld: 2, wantErr: true},
+			{now: day(14), flush: true, wantWebhook: 2},
+			{now: day(15), alerts: []Alert{warning, critical}},
+		}},
+		{"quiet hours hold and release", "22:00-07:00", []step{
+			{now: day(23), alerts: []Alert{warning, critical}, wantWebhook: 1, wantSMTP: 1, wantLog: 1, wantHeld: 1},
+			{now: day(23).Add(4 * time.Hour), flush: true, wantHeld: 1},
+			{now: day(23).Add(5 * time.Hour), alerts: []Alert{warning}, wantHeld: 1},
+			{now: day(23).Add(9 * time.Hour), flush: true, wantWebhook: 1, wantSMTP: 1, wantLog: 1},
+			{now: day(23).Add(10 * time.Hour), alerts: []Alert{warning, critical}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			webhook := newTestWebhook(t)
+			smtpServer := newTestSMTPServer(t)
+			logPath := filepath.Join(dir, "alerts.jsonl")
+			notifier := &Notifier{
+				sinks: []AlertSink{
+					&fileAlertSink{path: logPath},
+					&webhookAlertSink{url: webhook.URL, client: webhook.Client()},
+					&smtpAlertSink{addr: smtpServer.listener.Addr().String(), from: "cli@localhost", to: []string{"ops@localhost"}},
+				},
+				minSeverity: severityWarning,
+				location:    time.UTC,
+				statePath:   filepath.Join(dir, "state", "alerts.json"),
+			}
+			if tt.quiet != "" {
+				quiet, err := parseQuietHours(tt.quiet)
+				if err != nil {
+					t.Fatal(err)
+				}
+				notifier.quiet = &quiet
+			}
+
+			for i, s := range tt.steps {
+				webhook.failing.Store(s.failWebhook)
+				webhookBefore, smtpBefore, logBefore := webhook.alerts.Load(), smtpServer.count(), countLines(t, logPath)
+
+				var err error
+				if s.flush {
+					err = notifier.Flush(context.Background(), s.now)
+				} else {
+					err = notifier.Notify(context.Background(), s.alerts, s.now)
+				}
+				if (err != nil) != s.wantErr {
+					t.Errorf("step %d: error = %v, want an error: %v", i+1, err, s.wantErr)
+				}
+
+				if got := int(webhook.alerts.Load() - webhookBefore); got != s.wantWebhook {
+					t.Errorf("step %d: webhook got %d alerts, want %d", i+1, got, s.wantWebhook)
+				}
+				if got := smtpServer.count() - smtpBefore; got != s.wantSMTP {
+					t.Errorf("step %d: email had %d alerts, want %d", i+1, got, s.wantSMTP)
+				}
+				if got := countLines(t, logPath) - logBefore; got != s.wantLog {
+					t.Errorf("step %d: log got %d alerts, want %d", i+1, got, s.wantLog)
+				}
+				state, err := notifier.loadState()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(state.Held) != s.wantHeld {
+					t.Errorf("step %d: %d alerts held, want %d", i+1, len(state.Held), s.wantHeld)
+				}
+			}
+		})
+	}
+}
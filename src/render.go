@@ -0,0 +1,158 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// reportFormats are the formats a WeeklyReport can be saved in
+var reportFormats = []string{"json", "md", "html"}
+
+//go:embed templates/*.tmpl
+var reportTemplates embed.FS
+
+var templateFuncs = map[string]any{
+	"dayAnchor":     dayAnchor,
+	"accountAnchor": accountAnchor,
+	"tweetURL":      tweetURL,
+	"mdText":        mdText,
+	"paragraphs":    paragraphs,
+	"join":          strings.Join,
+	"inc":           func(i int) int { return i + 1 },
+}
+
+var (
+	markdownTemplate = template.Must(template.New("report.md.tmpl").Funcs(templateFuncs).ParseFS(reportTemplates, "templates/report.md.tmpl"))
+	htmlTemplate     = htmltemplate.Must(htmltemplate.New("report.html.tmpl").Funcs(templateFuncs).ParseFS(reportTemplates, "templates/report.html.tmpl"))
+)
+
+// parseReportFormats parses a comma-separated list of report formats
+func parseReportFormats(list string) ([]string, error) {
+	var formats []string
+	for _, format := range strings.Split(list, ",") {
+		format = strings.TrimSpace(format)
+		if format == "" {
+			continue
+		}
+		known := false
+		for _, f := range reportFormats {
+			known = known || f == format
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown report format %q (want %s)", format, strings.Join(reportFormats, ", "))
+		}
+		formats = append(formats, format)
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no report format given")
+	}
+	return formats, nil
+}
+
+// reportView is what the templates render: the report, plus the tweet counts per account and day
+type reportView struct {
+	WeeklyReport
+	AccountsList string
+	Counts       []accountCounts
+	DayTotals    []int
+}
+
+type accountCounts struct {
+	Username string
+	Days     []int // tweets on each day of DailyReports
+	Total    int
+}
+
+func newReportView(report WeeklyReport, accountsList string) reportView {
+	view := reportView{WeeklyReport: report, AccountsList: accountsList, DayTotals: make([]int, len(report.DailyReports))}
+	index := make(map[string]int)
+	for day, dailyReport := range report.DailyReports {
+		view.DayTotals[day] = dailyReport.TotalTweets
+		for _, accountReport := range dailyReport.AccountReports {
+			i, ok := index[accountReport.Username]
+			if !ok {
+				i = len(view.Counts)
+				index[accountReport.Username] = i
+				view.Counts = append(view.Counts, accountCounts{Username: accountReport.Username, Days: make([]int, len(report.DailyReports))})
+			}
+			view.Counts[i].Days[day] += accountReport.TweetCount
+			view.Counts[i].Total += accountReport.TweetCount
+		}
+	}
+	return view
+}
+
+// renderMarkdown writes report as a Markdown document
+func renderMarkdown(w io.Writer, report WeeklyReport, accountsList string) error {
+	return markdownTemplate.Execute(w, newReportView(report, accountsList))
+}
+
+// renderHTML writes report as a self-contained HTML page
+func renderHTML(w io.Writer, report WeeklyReport, accountsList string) error {
+	return htmlTemplate.Execute(w, newReportView(report, accountsList))
+}
+
+// saveRenderedReport renders report into a file in reportsDir
+func saveRenderedReport(report WeeklyReport, accountsList string, render func(io.Writer, WeeklyReport, string) error, reportsDir string, filename string) error {
+	if err := os.MkdirAll(reportsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create reports directory: %v", err)
+	}
+
+	filePath := filepath.Join(reportsDir, filename)
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %v", err)
+	}
+	if err := render(file, report, accountsList); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to render report: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write report file: %v", err)
+	}
+
+	fmt.Printf("Report saved to: %s\n", filePath)
+	return nil
+}
+
+func dayAnchor(date string) string {
+	return "day-" + date
+}
+
+func accountAnchor(date string, username string) string {
+	return "day-" + date + "-" + strings.ToLower(username)
+}
+
+func tweetURL(username string, tweetID string) string {
+	return fmt.Sprintf("https://x.com/%s/status/%s", username, tweetID)
+}
+
+// mdText collapses whitespace, so that a tweet fits in a Markdown list item or table cell,
+// and escapes the characters Markdown would otherwise take as HTML, emphasis, a link or a cell
+// boundary
+func mdText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	return mdEscaper.Replace(text)
+}
+
+var mdEscaper = strings.NewReplacer(
+	"&", "&amp;", "<", "&lt;", ">", "&gt;",
+	"\\", "\\\\", "|", "\\|", "*", "\\*", "_", "\\_", "`", "\\`", "[", "\\[", "]", "\\]",
+)
+
+// paragraphs splits LLM output on blank lines
+func paragraphs(text string) []string {
+	var paragraphs []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return paragraphs
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMdText(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"gm", "gm"},
+		{"line one\n\nline   two", "line one line two"},
+		{"<script>alert(1)</script>", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"a | b", `a \| b`},
+		{"AT&T", "AT&amp;T"},
+		{"[click](https://example.com) *now*", `\[click\](https://example.com) \*now\*`},
+		{"snake_case `code` C:\\", "snake\\_case \\`code\\` C:\\\\"},
+	}
+	for _, tt := range tests {
+		if got := mdText(tt.text); got != tt.want {
+			t.Errorf("mdText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMarkdownEscapesSummaries(t *testing.T) {
+	report := WeeklyReport{
+		StartDate:      "2025-01-06",
+		EndDate:        "2025-01-06",
+		Timezone:       "UTC",
+		OverallSummary: "First <img src=x onerror=alert(1)> paragraph.\n\nSecond | paragraph.",
+		DailyReports: []DailyReport{{
+			Date:        "2025-01-06",
+			TotalTweets: 1,
+			AccountReports: []AccountReport{{
+				Username:   "alice",
+				TweetCount: 1,
+				Summary:    "Alice posted <b>bold</b> claims.\n\nShe | replied.",
+				Claims: []Claim{{
+					Text:            "Alice posted claims",
+					TweetIDs:        []string{},
+					InvalidTweetIDs: []string{"999", "1 | [x](https://evil.example)\n# *owned*"},
+				}},
+			}},
+		}},
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"overall summary is escaped", "First &lt;img src=x onerror=alert(1)&gt; paragraph."},
+		{"overall summary keeps its paragraphs", "paragraph.\n\nSecond \\| paragraph."},
+		{"account summary is escaped", "Alice posted &lt;b&gt;bold&lt;/b&gt; claims."},
+		{"account summary keeps its paragraphs", "claims.\n\nShe \\| replied."},
+		{"made-up tweet IDs are escaped", `(cites unknown tweets 999, 1 \| \[x\](https://evil.example) # \*owned\*)`},
+	}
+
+	var out strings.Builder
+	if err := renderMarkdown(&out, report, "test"); err != nil {
+		t.Fatal(err)
+	}
+	rendered := out.String()
+	for _, tt := range tests {
+		if !strings.Contains(rendered, tt.want) {
+			t.Errorf("%s: rendered report doesn't contain %q", tt.name, tt.want)
+		}
+	}
+	for _, raw := range []string{"<img", "<b>", "[x]", "\n# "} {
+		if strings.Contains(rendered, raw) {
+			t.Errorf("rendered report contains raw %q", raw)
+		}
+	}
+}
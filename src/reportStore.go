@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrReportNotFound is returned by ReportStore.LoadDailyReport when no report is stored for the day
+var ErrReportNotFound = errors.New("report not found")
+
+// ReportStore keeps the daily reports and interactions of days that are over, so that
+// later runs over an overlapping window reuse them instead of asking the LLM again or
+// reading the tweets again. Missing days are ErrReportNotFound.
+type ReportStore interface {
+	LoadDailyReport(ctx context.Context, accountsList string, date time.Time) (DailyReport, error)
+	SaveDailyReport(ctx context.Context, accountsList string, report DailyReport) error
+	LoadDailyInteractions(ctx context.Context, accountsList string, date time.Time) (DailyInteractions, error)
+	SaveDailyInteractions(ctx context.Context, accountsList string, interactions DailyInteractions) error
+}
+
+// dailyReportSettle is how long after midnight a day counts as over. Tweets from the
+// last minutes of the day may only be fetched on the next fetch run.
+const dailyReportSettle = time.Hour
+
+// dayIsOver tells whether no more tweets are expected for the day of targetDate
+func dayIsOver(targetDate time.Time, now time.Time) bool {
+	return !now.Before(dayQuery(nil, targetDate).Until.Add(dailyReportSettle))
+}
+
+// reportFingerprint identifies what a daily report of accounts depends on besides the tweets,
+// the LLM and the timezone: which accounts are in it, how claims are verified, how prompt
+// injection is handled, and the instructions of the prompts. A stored report whose fingerprint
+// differs, e.g. because accounts were promoted into the list, is generated again.
+func (a *App) reportFingerprint(accounts []Account) string {
+	handles := accountHandles(accounts)
+	sort.Strings(handles)
+	prompts := summarizeInstructions + wrapData("")
+	if a.verify == verifyLLM {
+		prompts += checkClaimsInstructions
+	}
+	promptsSum := sha256.Sum256([]byte(prompts))
+	data, _ := json.Marshal(struct {
+		Accounts      []string `json:"accounts"`
+		AccountFilter string   `json:"account_filter"`
+		Verify        string   `json:"verify"`
+		Injection     string   `json:"injection"`
+		Prompts       string   `json:"prompts"`
+	}{handles, a.accountFilter.String(), a.verify, a.injection, hex.EncodeToString(promptsSum[:])})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// interactionsFingerprint identifies the accounts that daily interactions were extracted from.
+// Stored interactions whose fingerprint differs, because accounts were promoted into the list or
+// their active dates changed, are extracted again.
+func interactionsFingerprint(accounts []Account) string {
+	handles := accountHandles(accounts)
+	sort.Strings(handles)
+	sum := sha256.Sum256([]byte(strings.Join(handles, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// openReportStore keeps daily reports next to the tweets: in the database when tweets
+// are in postgres, and in dir otherwise
+func openReportStore(store TweetStore, dir string) (ReportStore, error) {
+	if s, ok := store.(*postgresStore); ok {
+		return s, nil
+	}
+	return newFileReportStore(dir)
+}
+
+// fileReportStore keeps each daily report as <dir>/<accounts list>/YYYY-MM-DD.json, and
+// each day's interactions as <dir>/<accounts list>/interactions/YYYY-MM-DD.json
+type fileReportStore struct {
+	dir string
+}
+
+func newFileReportStore(dir string) (*fileReportStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("report store directory not set")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create report store directory: %v", err)
+	}
+	return &fileReportStore{dir: dir}, nil
+}
+
+func (s *fileReportStore) path(accountsList string, date time.Time) string {
+	return filepath.Join(s.dir, accountsList, date.Format("2006-01-02")+".json")
+}
+
+func (s *fileReportStore) LoadDailyReport(ctx context.Context, accountsList string, date time.Time) (DailyReport, error) {
+	data, err := os.ReadFile(s.path(accountsList, date))
+	if errors.Is(err, os.ErrNotExist) {
+		return DailyReport{}, ErrReportNotFound
+	}
+	if err != nil {
+		return DailyReport{}, fmt.Errorf("failed to read daily report: %v", err)
+	}
+	var report DailyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return DailyReport{}, fmt.Errorf("failed to parse daily report %s: %v", s.path(accountsList, date), err)
+	}
+	return report, nil
+}
+
+func (s *fileReportStore) SaveDailyReport(ctx context.Context, accountsList string, report DailyReport) error {
+	date, err := time.Parse("2006-01-02", report.Date)
+	if err != nil {
+		return fmt.Errorf("invalid report date %q: %v", report.Date, err)
+	}
+	path := s.path(accountsList, date)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %v", err)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal daily report: %v", err)
+	}
+
+	// Write to a temporary file first, so that a crash never leaves half a report behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write daily report: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write daily report: %v", err)
+	}
+	return nil
+}
+
+func (s *fileReportStore) interactionsPath(accountsList string, date time.Time) string {
+	return filepath.Join(s.dir, accountsList, "interactions", date.Format("2006-01-02")+".json")
+}
+
+func (s *fileReportStore) LoadDailyInteractions(ctx context.Context, accountsList string, date time.Time) (DailyInteractions, error) {
+	path := s.interactionsPath(accountsList, date)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return DailyInteractions{}, ErrReportNotFound
+	}
+	if err != nil {
+		return DailyInteractions{}, fmt.Errorf("failed to read interactions: %v", err)
+	}
+	var interactions DailyInteractions
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return DailyInteractions{}, fmt.Errorf("failed to parse interactions %s: %v", path, err)
+	}
+	return interactions, nil
+}
+
+func (s *fileReportStore) SaveDailyInteractions(ctx context.Context, accountsList string, interactions DailyInteractions) error {
+	date, err := time.Parse("2006-01-02", interactions.Date)
+	if err != nil {
+		return fmt.Errorf("invalid interactions date %q: %v", interactions.Date, err)
+	}
+	path := s.interactionsPath(accountsList, date)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create interactions directory: %v", err)
+	}
+	data, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal interactions: %v", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write interactions: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write interactions: %v", err)
+	}
+	return nil
+}
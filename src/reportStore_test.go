@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDailyReportReuse(t *testing.T) {
+	day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		change    func(t *testing.T, app *App)
+		wantReuse bool
+	}{
+		{"nothing changed", func(t *testing.T, app *App) {}, true},
+		{"account promoted into the list", func(t *testing.T, app *App) {
+			path := filepath.Join(t.TempDir(), "candidates.json")
+			file := &CandidatesFile{AccountsList: "test", Candidates: []*Candidate{{Handle: "carol", Status: candidateApproved}}}
+			if err := saveCandidatesFile(path, file); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := promoteCandidates(path, ""); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"other verify mode", func(t *testing.T, app *App) { app.verify = verifyOff }, false},
+		{"other injection mode", func(t *testing.T, app *App) { app.injection = injectionQuarantine }, false},
+		{"other model", func(t *testing.T, app *App) { app.model = "gpt-4.1-mini" }, false},
+		{"stored without a fingerprint", func(t *testing.T, app *App) {
+			report, err := app.reports.LoadDailyReport(context.Background(), "test", day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			report.Fingerprint = ""
+			if err := app.reports.SaveDailyReport(context.Background(), "test", report); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"refresh", func(t *testing.T, app *App) { app.refresh = true }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeProvider()
+			app := newTestApp(t, fake, testTweets)
+			first, err := app.dailyReport(context.Background(), "test", day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first.Fingerprint == "" {
+				t.Fatal("the report of a day that is over wasn't stored with a fingerprint")
+			}
+			calls := len(fake.Calls())
+
+			tt.change(t, app)
+			second, err := app.dailyReport(context.Background(), "test", day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reused := len(fake.Calls()) == calls
+			if reused != tt.wantReuse {
+				t.Errorf("reused = %v, want %v", reused, tt.wantReuse)
+			}
+			if !reused && second.Fingerprint != app.reportFingerprint(mustSelectAccounts(t, app, day)) {
+				t.Errorf("the regenerated report wasn't stored with the new fingerprint")
+			}
+		})
+	}
+}
+
+func mustSelectAccounts(t *testing.T, app *App, day time.Time) []Account {
+	t.Helper()
+	accounts, err := app.selectAccounts("test", day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return accounts
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// schedulerTick is how often the Scheduler checks whether something is due
+const schedulerTick = time.Minute
+
+// schedulerAnomalyDays is the window of the Scheduler's anomaly checks: each reported day is
+// checked on its own, so that alerts go out the day after rather than with the weekly report
+const schedulerAnomalyDays = 1
+
+// Scheduler fetches tweets on an interval and makes the daily report after midnight and
+// the weekly report on a given weekday, for the serve command. Its progress is
+// checkpointed to a state file, so a restart resumes without redoing finished work.
+type Scheduler struct {
+	app          *App
+	fetcher      *Fetcher // nil when tweets are fetched by something else
+	accountsList string
+	location     *time.Location
+	fetchEvery   time.Duration
+	reportAfter  time.Duration // how long after midnight the previous day is reported on
+	weeklyOn     time.Weekday
+	weeklyDays   int
+	statePath    string
+
+	state        schedulerState
+	reportsAfter time.Time // reports that failed are retried after this
+}
+
+// schedulerState is what the Scheduler has done so far
+type schedulerState struct {
+	LastFetch  time.Time `json:"last_fetch"`
+	LastDaily  string    `json:"last_daily,omitempty"`  // day of the latest daily report
+	LastWeekly string    `json:"last_weekly,omitempty"` // last day of the latest weekly report
+}
+
+// Run does whatever is due every schedulerTick until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) error {
+	if err := s.loadState(); err != nil {
+		return err
+	}
+	fmt.Printf("Scheduler started for %s in %s: fetching every %s, daily reports %s after midnight, weekly reports on %s\n",
+		s.accountsList, s.location, s.fetchEvery, s.reportAfter, s.weeklyOn)
+
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+	for {
+		if err := s.tick(ctx, time.Now()); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick runs the jobs that are due at now. Only cancellation and checkpoint failures are
+// returned; failing jobs are reported and retried later.
+func (s *Scheduler) tick(ctx context.Context, now time.Time) error {
+	now = now.In(s.location)
+
+	if s.fetcher != nil && now.Sub(s.state.LastFetch) >= s.fetchEvery {
+		stats, err := s.fetcher.Run(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			fmt.Printf("Warning: fetch failed: %v\n", err)
+		} else {
+			fmt.Printf("Fetched %d accounts (%d failed): %d tweets, %d new\n", stats.Accounts, stats.Failed, stats.Fetched, stats.Inserted)
+		}
+		// A failed fetch waits for the next interval too, rather than hammer the source
+		s.state.LastFetch = now
+		if err := s.saveState(); err != nil {
+			return err
+		}
+	}
+
+	// Alerts held back during quiet hours, or that no sink took, go out when they can
+	if s.app.notifier != nil {
+		if err := s.app.notifier.Flush(ctx, now); err != nil {
+			fmt.Printf("Warning: failed to deliver alerts: %v\n", err)
+		}
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.location)
+	if now.Before(today.Add(s.reportAfter)) || now.Before(s.reportsAfter) {
+		return nil
+	}
+	yesterday := today.AddDate(0, 0, -1)
+
+	for day := s.firstMissingDay(yesterday); !day.After(yesterday); day = day.AddDate(0, 0, 1) {
+		if err := s.app.GenerateDailyReportFile(ctx, s.accountsList, day); err != nil {
+			return s.reportFailed(ctx, now, err)
+		}
+		// Alerts go out daily rather than waiting for the weekly report, which then doesn't
+		// send the days' alerts again. Its schedule and reply alerts cover its whole window,
+		// so they're still sent.
+		alerts, err := s.app.detectAnomalies(ctx, s.accountsList, day, schedulerAnomalyDays)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			fmt.Printf("Warning: failed to detect anomalies for %s: %v\n", day.Format(dateLayout), err)
+		}
+		s.app.notifyAlerts(ctx, alerts)
+		s.state.LastDaily = day.Format(dateLayout)
+		if err := s.saveState(); err != nil {
+			return err
+		}
+	}
+
+	if now.Weekday() == s.weeklyOn && s.state.LastWeekly < yesterday.Format(dateLayout) {
+		startDate := yesterday.AddDate(0, 0, -(s.weeklyDays - 1))
+		if err := s.app.GenerateReports(ctx, s.accountsList, startDate, s.weeklyDays); err != nil {
+			return s.reportFailed(ctx, now, err)
+		}
+		s.state.LastWeekly = yesterday.Format(dateLayout)
+		if err := s.saveState(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firstMissingDay is the first day without a daily report. After downtime, missed days are
+// caught up, but no further back than the weekly window; on the first run, only yesterday is.
+func (s *Scheduler) firstMissingDay(yesterday time.Time) time.Time {
+	if s.state.LastDaily == "" {
+		return yesterday
+	}
+	last, err := time.ParseInLocation(dateLayout, s.state.LastDaily, s.location)
+	if err != nil {
+		return yesterday
+	}
+	earliest := yesterday.AddDate(0, 0, -(s.weeklyDays - 1))
+	if day := last.AddDate(0, 0, 1); day.After(earliest) {
+		return day
+	}
+	return earliest
+}
+
+// reportFailed reports a failed report and holds reports back for a fetch interval from now
+func (s *Scheduler) reportFailed(ctx context.Context, now time.Time, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	fmt.Printf("Warning: %v; retrying in %s\n", err, s.fetchEvery)
+	s.reportsAfter = now.Add(s.fetchEvery)
+	return nil
+}
+
+func (s *Scheduler) loadState() error {
+	data, err := os.ReadFile(s.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read scheduler state: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return fmt.Errorf("failed to parse scheduler state %s: %v", s.statePath, err)
+	}
+	return nil
+}
+
+// saveState writes the checkpoint through a temporary file, so a crash never leaves half of it
+func (s *Scheduler) saveState() error {
+	if err := os.MkdirAll(filepath.Dir(s.statePath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduler state: %v", err)
+	}
+	tmp := s.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write scheduler state: %v", err)
+	}
+	if err := os.Rename(tmp, s.statePath); err != nil {
+		return fmt.Errorf("failed to write scheduler state: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writtenReports returns the report files in dir, and removes them, so that each tick of a
+// test sees only the reports it wrote
+func writtenReports(t *testing.T, dir string) []string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, path := range paths {
+		names = append(names, strings.TrimSuffix(filepath.Base(path), ".json"))
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return names
+}
+
+func TestSchedulerTick(t *testing.T) {
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2025, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	// A step is a tick at now, in a scheduler restarted from its state file if restart is set,
+	// and the reports it should write
+	type step struct {
+		now         time.Time
+		restart     bool
+		failLLM     bool
+		want        []string
+		wantLastDay string // LastDaily in the state file afterwards
+	}
+	tests := []struct {
+		name      string
+		lastDaily string // of the state file before the first step
+		steps     []step
+	}{
+		{"first run reports yesterday only", "", []step{
+			{now: at(9, 1, 0), wantLastDay: ""},
+			{now: at(9, 3, 0), want: []string{"daily_test_2025-01-08"}, wantLastDay: "2025-01-08"},
+		}},
+		{"no double runs", "", []step{
+			{now: at(9, 3, 0), want: []string{"daily_test_2025-01-08"}, wantLastDay: "2025-01-08"},
+			{now: at(9, 3, 1), wantLastDay: "2025-01-08"},
+			{now: at(9, 23, 0), wantLastDay: "2025-01-08"},
+			{now: at(10, 3, 0), want: []string{"daily_test_2025-01-09"}, wantLastDay: "2025-01-09"},
+		}},
+		{"resume from the checkpoint", "", []step{
+			{now: at(9, 3, 0), want: []string{"daily_test_2025-01-08"}, wantLastDay: "2025-01-08"},
+			{now: at(9, 4, 0), restart: true, wantLastDay: "2025-01-08"},
+		}},
+		{"catch up on missed days", "2025-01-05", []step{
+			{now: at(9, 3, 0), want: []string{"daily_test_2025-01-06", "daily_test_2025-01-07", "daily_test_2025-01-08"}, wantLastDay: "2025-01-08"},
+		}},
+		{"catch up no further than the weekly window", "2024-12-01", []step{
+			{now: at(5, 3, 0), want: []string{
+				"daily_test_2024-12-29", "daily_test_2024-12-30", "daily_test_2024-12-31",
+				"daily_test_2025-01-01", "daily_test_2025-01-02", "daily_test_2025-01-03", "daily_test_2025-01-04",
+			}, wantLastDay: "2025-01-04"},
+		}},
+		{"weekly report", "2025-01-11", []step{
+			{now: at(13, 3, 0), want: []string{"daily_test_2025-01-12", "report_test_2025-01-06_to_2025-01-12"}, wantLastDay: "2025-01-12"},
+			{now: at(13, 4, 0), restart: true, wantLastDay: "2025-01-12"},
+		}},
+		{"failed report is retried after the fetch interval", "2025-01-05", []step{
+			{now: at(8, 3, 0), failLLM: true, wantLastDay: "2025-01-05"},
+			{now: at(8, 3, 30), wantLastDay: "2025-01-05"},
+			{now: at(8, 4, 0), want: []string{"daily_test_2025-01-06", "daily_test_2025-01-07"}, wantLastDay: "2025-01-07"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeProvider()
+			app := newTestApp(t, fake, testTweets)
+			statePath := filepath.Join(t.TempDir(), "scheduler.json")
+			newScheduler := func() *Scheduler {
+				s := &Scheduler{
+					app:          app,
+					accountsList: "test",
+					location:     time.UTC,
+					fetchEvery:   time.Hour,
+					reportAfter:  90 * time.Minute,
+					weeklyOn:     time.Monday,
+					weeklyDays:   7,
+					statePath:    statePath,
+				}
+				if err := s.loadState(); err != nil {
+					t.Fatal(err)
+				}
+				return s
+			}
+			s := newScheduler()
+			if tt.lastDaily != "" {
+				s.state.LastDaily = tt.lastDaily
+				if err := s.saveState(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for i, step := range tt.steps {
+				if step.restart {
+					s = newScheduler()
+				}
+				fake.failErr = nil
+				if step.failLLM {
+					fake.withFailure(0, errCostBudgetExceeded)
+				}
+				if err := s.tick(context.Background(), step.now); err != nil {
+					t.Fatalf("step %d: tick: %v", i+1, err)
+				}
+				if got := writtenReports(t, app.outputDir); !slices.Equal(got, step.want) {
+					t.Errorf("step %d: wrote %v, want %v", i+1, got, step.want)
+				}
+				if got := newScheduler().state.LastDaily; got != step.wantLastDay {
+					t.Errorf("step %d: checkpoint has the last daily report on %q, want %q", i+1, got, step.wantLastDay)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TimelineSource is somewhere the Fetcher can get an account's tweets from.
+// client is the Fetcher's HTTP client; sources that don't use the network ignore it.
+type TimelineSource interface {
+	Name() string
+	// FetchTimeline returns the tweets of username created at or after since.
+	// known holds the IDs of tweets already stored; sources that page through a
+	// timeline stop at the first page that has one.
+	FetchTimeline(ctx context.Context, client *http.Client, username string, since time.Time, known map[string]bool) ([]Tweet, error)
+}
+
+// timelineMaxPages caps the pages fetched for one account, in case an API keeps
+// answering with a next cursor
+const timelineMaxPages = 50
+
+// httpTimelineSource reads timelines from an HTTP API that answers with a TimelineResponse,
+// newest tweets first. urlTemplate contains {username}, and optionally {since} as RFC 3339
+// and {cursor}; without {cursor}, the next page is asked for with a cursor query parameter.
+type httpTimelineSource struct {
+	urlTemplate string
+	token       string
+}
+
+func newHTTPTimelineSource(urlTemplate string, token string) (*httpTimelineSource, error) {
+	if urlTemplate == "" {
+		return nil, fmt.Errorf("TIMELINE_API_URL environment variable not set")
+	}
+	if !strings.Contains(urlTemplate, "{username}") {
+		return nil, fmt.Errorf("TIMELINE_API_URL must contain {username}, got %q", urlTemplate)
+	}
+	return &httpTimelineSource{urlTemplate: urlTemplate, token: token}, nil
+}
+
+func (s *httpTimelineSource) Name() string {
+	return "http"
+}
+
+func (s *httpTimelineSource) FetchTimeline(ctx context.Context, client *http.Client, username string, since time.Time, known map[string]bool) ([]Tweet, error) {
+	var tweets []Tweet
+	cursor := ""
+	for page := 0; page < timelineMaxPages; page++ {
+		pageTweets, next, done, err := s.fetchPage(ctx, client, username, since, known, cursor)
+		if err != nil {
+			return []Tweet{}, err
+		}
+		tweets = append(tweets, pageTweets...)
+		if done || next == "" || next == cursor {
+			return tweets, nil
+		}
+		cursor = next
+	}
+	fmt.Printf("Warning: stopped fetching @%s after %d pages\n", username, timelineMaxPages)
+	return tweets, nil
+}
+
+// pageURL returns the URL of the page of username's timeline at cursor; the first page has none
+func (s *httpTimelineSource) pageURL(username string, since time.Time, cursor string) string {
+	requestURL := strings.NewReplacer(
+		"{username}", url.PathEscape(username),
+		"{since}", url.QueryEscape(since.UTC().Format(time.RFC3339)),
+		"{cursor}", url.QueryEscape(cursor),
+	).Replace(s.urlTemplate)
+	if cursor == "" || strings.Contains(s.urlTemplate, "{cursor}") {
+		return requestURL
+	}
+	separator := "?"
+	if strings.Contains(requestURL, "?") {
+		separator = "&"
+	}
+	return requestURL + separator + "cursor=" + url.QueryEscape(cursor)
+}
+
+// fetchPage fetches one page of username's timeline. It returns the page's tweets created
+// at or after since, the cursor of the next page, and whether the page reached tweets that
+// are older than since or already known, so there is no need to fetch older pages.
+func (s *httpTimelineSource) fetchPage(ctx context.Context, client *http.Client, username string, since time.Time, known map[string]bool, cursor string) ([]Tweet, string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.pageURL(username, since, cursor), nil)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("failed to fetch timeline: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, "", false, fmt.Errorf("timeline API returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	// The response is a TimelineResponse; each tweet is kept raw as well as decoded
+	var timeline struct {
+		Timeline []json.RawMessage `json:"timeline"`
+		Next     string            `json:"next"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&timeline); err != nil {
+		return nil, "", false, fmt.Errorf("failed to decode timeline: %v", err)
+	}
+
+	var tweets []Tweet
+	done := false
+	for _, raw := range timeline.Timeline {
+		var tweet Tweet
+		if err := json.Unmarshal(raw, &tweet); err != nil {
+			return nil, "", false, fmt.Errorf("failed to decode tweet: %v", err)
+		}
+		tweet.Raw = raw
+		createdAt, err := parseTweetTime(tweet.CreatedAt)
+		if err != nil {
+			return nil, "", false, fmt.Errorf("tweet %s: %v", tweet.ID, err)
+		}
+		if known[tweet.ID] {
+			done = true
+		}
+		if createdAt.Before(since) {
+			done = true
+			continue
+		}
+		// Store times the way the stores write them
+		tweet.CreatedAt = createdAt.UTC().Format("2006-01-02 15:04:05")
+		tweets = append(tweets, tweet)
+	}
+	return tweets, timeline.Next, done, nil
+}
+
+// storeTimelineSource reads timelines out of another TweetStore, e.g. a JSONL dump
+// from a scraper, so its tweets can be imported into the main store
+type storeTimelineSource struct {
+	name  string
+	store TweetStore
+}
+
+func (s *storeTimelineSource) Name() string {
+	return s.name
+}
+
+func (s *storeTimelineSource) FetchTimeline(ctx context.Context, client *http.Client, username string, since time.Time, known map[string]bool) ([]Tweet, error) {
+	return s.store.QueryTweets(ctx, TweetQuery{Accounts: []string{username}, Since: since})
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTweetNotFound is returned by TweetStore.GetTweet when no tweet has the given ID
+var ErrTweetNotFound = errors.New("tweet not found")
+
+// TweetQuery selects tweets from a TweetStore. Zero-valued fields don't filter.
+type TweetQuery struct {
+	Accounts []string  // usernames to include; empty means every account
+	Since    time.Time // inclusive lower bound on created_at
+	Until    time.Time // exclusive upper bound on created_at
+	Text     string    // case-insensitive substring of the tweet text
+	Limit    int       // maximum number of tweets returned; 0 means no limit
+}
+
+// TweetStore is where tweets are read from and saved to. Results are ordered newest first.
+type TweetStore interface {
+	QueryTweets(ctx context.Context, q TweetQuery) ([]Tweet, error)
+	// EachTweet calls fn with each tweet QueryTweets would return, without holding them all
+	// in memory, and stops at the first error fn returns
+	EachTweet(ctx context.Context, q TweetQuery, fn func(Tweet) error) error
+	GetTweet(ctx context.Context, tweetID string) (Tweet, error)
+	// UpsertTweets saves tweets, replacing stored tweets with the same tweet_id,
+	// and returns how many of them were new
+	UpsertTweets(ctx context.Context, tweets []Tweet) (int, error)
+	Close()
+}
+
+// dayQuery returns a query for the tweets of accounts created on the day of targetDate
+func dayQuery(accounts []string, targetDate time.Time) TweetQuery {
+	startOfDay := time.Date(targetDate.Year(), targetDate.Month(), targetDate.Day(), 0, 0, 0, 0, targetDate.Location())
+	return TweetQuery{
+		Accounts: accounts,
+		Since:    startOfDay,
+		Until:    startOfDay.AddDate(0, 0, 1),
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileStore keeps tweets as JSON lines in one file per day, named YYYY-MM-DD.jsonl after the
+// UTC date of created_at. It needs no database, so reports can run from a dump on a laptop.
+type fileStore struct {
+	dir string
+}
+
+func newFileStore(dir string) (*fileStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("tweet store directory not set")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tweet store directory: %v", err)
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+func (s *fileStore) Close() {}
+
+func (s *fileStore) QueryTweets(ctx context.Context, q TweetQuery) ([]Tweet, error) {
+	var tweets []Tweet
+	err := s.EachTweet(ctx, q, func(tweet Tweet) error {
+		tweets = append(tweets, tweet)
+		return nil
+	})
+	if err != nil {
+		return []Tweet{}, err
+	}
+	return tweets, nil
+}
+
+// EachTweet reads one day file at a time, newest first, so memory is bounded by a day's tweets
+func (s *fileStore) EachTweet(ctx context.Context, q TweetQuery, fn func(Tweet) error) error {
+	paths, err := s.dayFiles(q.Since, q.Until)
+	if err != nil {
+		return err
+	}
+
+	accounts := make(map[string]bool)
+	for _, account := range q.Accounts {
+		accounts[account] = true
+	}
+	text := strings.ToLower(q.Text)
+
+	sent := 0
+	for i := len(paths) - 1; i >= 0; i-- {
+		path := paths[i]
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dayTweets, err := readTweetsFile(path)
+		if err != nil {
+			return err
+		}
+		var matching []Tweet
+		for _, tweet := range dayTweets {
+			if len(accounts) > 0 && !accounts[tweet.Username] {
+				continue
+			}
+			if text != "" && !strings.Contains(strings.ToLower(tweet.Text), text) {
+				continue
+			}
+			createdAt, err := parseTweetTime(tweet.CreatedAt)
+			if err != nil {
+				return fmt.Errorf("tweet %s in %s: %v", tweet.ID, path, err)
+			}
+			if !q.Since.IsZero() && createdAt.Before(q.Since) {
+				continue
+			}
+			if !q.Until.IsZero() && !createdAt.Before(q.Until) {
+				continue
+			}
+			matching = append(matching, tweet)
+		}
+
+		// Day files hold disjoint UTC days, so sorting each one orders them all
+		sortTweetsNewestFirst(matching)
+		for _, tweet := range matching {
+			if err := fn(tweet); err != nil {
+				return err
+			}
+			if sent++; q.Limit > 0 && sent >= q.Limit {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+func (s *fileStore) GetTweet(ctx context.Context, tweetID string) (Tweet, error) {
+	paths, err := s.dayFiles(time.Time{}, time.Time{})
+	if err != nil {
+		return Tweet{}, err
+	}
+	for _, path := range paths {
+		tweets, err := readTweetsFile(path)
+		if err != nil {
+			return Tweet{}, err
+		}
+		for _, tweet := range tweets {
+			if tweet.ID == tweetID {
+				return tweet, nil
+			}
+		}
+	}
+	return Tweet{}, ErrTweetNotFound
+}
+
+// UpsertTweets merges tweets into their day files, replacing tweets with the same ID
+func (s *fileStore) UpsertTweets(ctx context.Context, tweets []Tweet) (int, error) {
+	byDay := make(map[string][]Tweet)
+	for _, tweet := range tweets {
+		createdAt, err := parseTweetTime(tweet.CreatedAt)
+		if err != nil {
+			return 0, fmt.Errorf("tweet %s: %v", tweet.ID, err)
+		}
+		day := createdAt.UTC().Format("2006-01-02")
+		byDay[day] = append(byDay[day], tweet)
+	}
+
+	inserted := 0
+	for day, dayTweets := range byDay {
+		if err := ctx.Err(); err != nil {
+			return inserted, err
+		}
+		path := filepath.Join(s.dir, day+".jsonl")
+		existing, err := readTweetsFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return inserted, err
+		}
+
+		merged := make(map[string]Tweet)
+		for _, tweet := range existing {
+			merged[tweet.ID] = tweet
+		}
+		for _, tweet := range dayTweets {
+			if _, ok := merged[tweet.ID]; !ok {
+				inserted++
+			}
+			merged[tweet.ID] = tweet
+		}
+		all := make([]Tweet, 0, len(merged))
+		for _, tweet := range merged {
+			all = append(all, tweet)
+		}
+		sortTweetsNewestFirst(all)
+
+		if err := writeTweetsFile(path, all); err != nil {
+			return inserted, err
+		}
+	}
+	return inserted, nil
+}
+
+// dayFiles lists the day files that may hold tweets created in [since, until).
+// Files one day either side are kept, since a day file holds a UTC day.
+func (s *fileStore) dayFiles(since time.Time, until time.Time) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	if err != nil {
+		return []string{}, fmt.Errorf("failed to list tweet files: %v", err)
+	}
+
+	var selected []string
+	for _, path := range paths {
+		day, err := time.Parse("2006-01-02", strings.TrimSuffix(filepath.Base(path), ".jsonl"))
+		if err != nil {
+			continue
+		}
+		if !since.IsZero() && day.Before(since.AddDate(0, 0, -1)) {
+			continue
+		}
+		if !until.IsZero() && day.After(until.AddDate(0, 0, 1)) {
+			continue
+		}
+		selected = append(selected, path)
+	}
+	sort.Strings(selected)
+	return selected, nil
+}
+
+func readTweetsFile(path string) ([]Tweet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return []Tweet{}, err
+	}
+	defer file.Close()
+
+	var tweets []Tweet
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var tweet Tweet
+		if err := json.Unmarshal(scanner.Bytes(), &tweet); err != nil {
+			return []Tweet{}, fmt.Errorf("failed to parse %s line %d: %v", path, line, err)
+		}
+		tweets = append(tweets, tweet)
+	}
+	if err := scanner.Err(); err != nil {
+		return []Tweet{}, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return tweets, nil
+}
+
+// writeTweetsFile replaces path atomically, so a crash never leaves a half-written day
+func writeTweetsFile(path string, tweets []Tweet) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", tmpPath, err)
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	encoder.SetEscapeHTML(false)
+	for _, tweet := range tweets {
+		if err := encoder.Encode(tweet); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to encode tweet %s: %v", tweet.ID, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %v", tmpPath, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %v", tmpPath, err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// parseTweetTime parses created_at as written by the stores, or as RFC 3339.
+// Times without a zone are UTC, which is how both stores write them.
+func parseTweetTime(createdAt string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02 15:04:05", createdAt); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid created_at %q", createdAt)
+	}
+	return t, nil
+}
+
+func sortTweetsNewestFirst(tweets []Tweet) {
+	sort.SliceStable(tweets, func(i, j int) bool {
+		ti, _ := parseTweetTime(tweets[i].CreatedAt)
+		tj, _ := parseTweetTime(tweets[j].CreatedAt)
+		return ti.After(tj)
+	})
+}
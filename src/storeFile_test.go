@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// storeTweets span three UTC days, with tweets right at midnight
+var storeTweets = []Tweet{
+	{ID: "1", Username: "alice", CreatedAt: "2025-01-05 23:30:00", Text: "late night"},
+	{ID: "2", Username: "bob", CreatedAt: "2025-01-06 00:00:00", Text: "Midnight GM"},
+	{ID: "3", Username: "alice", CreatedAt: "2025-01-06 09:00:00", Text: "gm"},
+	{ID: "4", Username: "carol", CreatedAt: "2025-01-06 12:00:00", Text: "lunch"},
+	{ID: "5", Username: "alice", CreatedAt: "2025-01-06 23:59:59", Text: "gn"},
+	{ID: "6", Username: "bob", CreatedAt: "2025-01-07 00:00:00", Text: "gm again"},
+}
+
+func newTestFileStore(t *testing.T, tweets []Tweet) *fileStore {
+	t.Helper()
+	store, err := newFileStore(filepath.Join(t.TempDir(), "tweets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UpsertTweets(context.Background(), tweets); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestFileStoreUpsert(t *testing.T) {
+	ctx := context.Background()
+	store := newTestFileStore(t, nil)
+
+	inserted, err := store.UpsertTweets(ctx, storeTweets)
+	if err != nil || inserted != len(storeTweets) {
+		t.Fatalf("inserted %d, %v, want %d", inserted, err, len(storeTweets))
+	}
+	files, _ := filepath.Glob(filepath.Join(store.dir, "*.jsonl"))
+	if len(files) != 3 {
+		t.Errorf("%d day files, want one per UTC day", len(files))
+	}
+
+	edited := storeTweets[2]
+	edited.Text = "gm, edited"
+	edited.LikeCount = 3
+	inserted, err = store.UpsertTweets(ctx, []Tweet{edited, storeTweets[4], {ID: "7", Username: "alice", CreatedAt: "2025-01-06T10:00:00Z", Text: "new"}})
+	if err != nil || inserted != 1 {
+		t.Fatalf("inserted %d, %v, want only the new tweet", inserted, err)
+	}
+
+	got, err := store.GetTweet(ctx, "3")
+	if err != nil || got.Text != "gm, edited" || got.LikeCount != 3 {
+		t.Errorf("tweet 3 = %+v, %v, want the edited tweet", got, err)
+	}
+	if _, err := store.GetTweet(ctx, "404"); !errors.Is(err, ErrTweetNotFound) {
+		t.Errorf("missing tweet: error = %v, want ErrTweetNotFound", err)
+	}
+	all, err := store.QueryTweets(ctx, TweetQuery{})
+	if err != nil || len(all) != 7 {
+		t.Errorf("%d tweets stored, %v, want 7 without duplicates", len(all), err)
+	}
+}
+
+func TestFileStoreQuery(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no tzdata:", err)
+	}
+	utc := func(day int, hour int) time.Time { return time.Date(2025, 1, day, hour, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name  string
+		query TweetQuery
+		want  []string // IDs, newest first
+	}{
+		{"everything", TweetQuery{}, []string{"6", "5", "4", "3", "2", "1"}},
+		{"accounts", TweetQuery{Accounts: []string{"alice", "carol"}}, []string{"5", "4", "3", "1"}},
+		{"a UTC day, half-open", dayQuery(nil, utc(6, 0)), []string{"5", "4", "3", "2"}},
+		{"since is inclusive", TweetQuery{Since: utc(7, 0)}, []string{"6"}},
+		{"until is exclusive", TweetQuery{Until: utc(6, 0)}, []string{"1"}},
+		// Midnight in Berlin is 23:00 UTC, so the day spans two day files
+		{"a day in another timezone", dayQuery(nil, time.Date(2025, 1, 6, 0, 0, 0, 0, berlin)), []string{"4", "3", "2", "1"}},
+		{"text", TweetQuery{Text: "GM"}, []string{"6", "3", "2"}},
+		{"limit across day files", TweetQuery{Limit: 3}, []string{"6", "5", "4"}},
+		{"everything together", TweetQuery{Accounts: []string{"alice", "bob"}, Since: utc(6, 0), Until: utc(8, 0), Text: "gm", Limit: 2}, []string{"6", "3"}},
+		{"nothing", TweetQuery{Accounts: []string{"dave"}}, nil},
+	}
+
+	store := newTestFileStore(t, storeTweets)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tweets, err := store.QueryTweets(context.Background(), tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, tweet := range tweets {
+				ids = append(ids, tweet.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("tweets = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileStoreEachTweetStops(t *testing.T) {
+	store := newTestFileStore(t, storeTweets)
+	stop := errors.New("stop")
+	var seen []string
+	err := store.EachTweet(context.Background(), TweetQuery{}, func(tweet Tweet) error {
+		seen = append(seen, tweet.ID)
+		if len(seen) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || !slices.Equal(seen, []string{"6", "5"}) {
+		t.Errorf("saw %v and returned %v, want to stop after 6 and 5 with fn's error", seen, err)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresStore reads tweets from the tweets0x001 table through a shared connection pool
+type postgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// tweetColumns are the columns scanned by scanTweet. Columns added after the original
+// schema are NULL in older rows, so they're coalesced to their zero values.
+const tweetColumns = `tweet_id, tweet_text, username, created_at,
+	COALESCE(conversation_id, ''), COALESCE(in_reply_to_id, ''), COALESCE(in_reply_to_username, ''),
+	COALESCE(quoted_id, ''), COALESCE(quoted_username, ''), COALESCE(retweeted_id, ''), COALESCE(retweeted_username, ''),
+	COALESCE(lang, ''), COALESCE(urls, '{}'), COALESCE(media, '{}'),
+	COALESCE(like_count, 0), COALESCE(retweet_count, 0), COALESCE(reply_count, 0), COALESCE(quote_count, 0),
+	fetched_at, raw`
+
+// newPostgresStore connects to the database and brings its schema up to date
+func newPostgresStore(ctx context.Context, url string) (*postgresStore, error) {
+	if url == "" {
+		return nil, fmt.Errorf("DATABASE_POOL_URL environment variable not set")
+	}
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create database pool: %v", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+	if err := migrate(ctx, pool); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
+	}
+	return &postgresStore{pool: pool}, nil
+}
+
+func (s *postgresStore) Close() {
+	s.pool.Close()
+}
+
+func (s *postgresStore) QueryTweets(ctx context.Context, q TweetQuery) ([]Tweet, error) {
+	var tweets []Tweet
+	err := s.EachTweet(ctx, q, func(tweet Tweet) error {
+		tweets = append(tweets, tweet)
+		return nil
+	})
+	if err != nil {
+		return []Tweet{}, err
+	}
+	return tweets, nil
+}
+
+// EachTweet filters by account, window and text in the query, so only matching rows leave
+// the database, and scans them one at a time
+func (s *postgresStore) EachTweet(ctx context.Context, q TweetQuery, fn func(Tweet) error) error {
+	var conditions []string
+	var args []any
+	if len(q.Accounts) > 0 {
+		args = append(args, q.Accounts)
+		conditions = append(conditions, fmt.Sprintf("username = ANY($%d)", len(args)))
+	}
+	if !q.Since.IsZero() {
+		args = append(args, q.Since)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !q.Until.IsZero() {
+		args = append(args, q.Until)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if q.Text != "" {
+		args = append(args, q.Text)
+		conditions = append(conditions, fmt.Sprintf("tweet_text ILIKE '%%' || $%d || '%%'", len(args)))
+	}
+
+	sql := "SELECT " + tweetColumns + " FROM tweets0x001"
+	if len(conditions) > 0 {
+		sql += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sql += " ORDER BY created_at DESC"
+	if q.Limit > 0 {
+		args = append(args, q.Limit)
+		sql += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query tweets: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tweet, err := scanTweet(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(tweet); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read tweets: %v", err)
+	}
+	return nil
+}
+
+func (s *postgresStore) GetTweet(ctx context.Context, tweetID string) (Tweet, error) {
+	rows, err := s.pool.Query(ctx, "SELECT "+tweetColumns+" FROM tweets0x001 WHERE tweet_id = $1", tweetID)
+	if err != nil {
+		return Tweet{}, fmt.Errorf("failed to query tweet %s: %v", tweetID, err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return Tweet{}, fmt.Errorf("failed to read tweet %s: %v", tweetID, err)
+		}
+		return Tweet{}, ErrTweetNotFound
+	}
+	return scanTweet(rows)
+}
+
+func (s *postgresStore) UpsertTweets(ctx context.Context, tweets []Tweet) (int, error) {
+	batch := &pgx.Batch{}
+	for _, tweet := range tweets {
+		createdAt, err := parseTweetTime(tweet.CreatedAt)
+		if err != nil {
+			return 0, fmt.Errorf("tweet %s: %v", tweet.ID, err)
+		}
+		fetchedAt := time.Now()
+		if tweet.FetchedAt != "" {
+			if fetchedAt, err = parseTweetTime(tweet.FetchedAt); err != nil {
+				return 0, fmt.Errorf("tweet %s: %v", tweet.ID, err)
+			}
+		}
+		var raw any
+		if len(tweet.Raw) > 0 {
+			raw = string(tweet.Raw)
+		}
+		// Optional fields the source didn't send keep their stored values.
+		// xmax is 0 for freshly inserted rows, and set for rows updated on conflict.
+		batch.Queue(`INSERT INTO tweets0x001 (tweet_id, tweet_text, created_at, username,
+				conversation_id, in_reply_to_id, in_reply_to_username, quoted_id, quoted_username,
+				retweeted_id, retweeted_username, lang, urls, media,
+				like_count, retweet_count, reply_count, quote_count, fetched_at, raw)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+			ON CONFLICT (tweet_id) DO UPDATE SET
+				tweet_text = EXCLUDED.tweet_text,
+				created_at = EXCLUDED.created_at,
+				username = EXCLUDED.username,
+				conversation_id = COALESCE(EXCLUDED.conversation_id, tweets0x001.conversation_id),
+				in_reply_to_id = COALESCE(EXCLUDED.in_reply_to_id, tweets0x001.in_reply_to_id),
+				in_reply_to_username = COALESCE(EXCLUDED.in_reply_to_username, tweets0x001.in_reply_to_username),
+				quoted_id = COALESCE(EXCLUDED.quoted_id, tweets0x001.quoted_id),
+				quoted_username = COALESCE(EXCLUDED.quoted_username, tweets0x001.quoted_username),
+				retweeted_id = COALESCE(EXCLUDED.retweeted_id, tweets0x001.retweeted_id),
+				retweeted_username = COALESCE(EXCLUDED.retweeted_username, tweets0x001.retweeted_username),
+				lang = COALESCE(EXCLUDED.lang, tweets0x001.lang),
+				urls = COALESCE(EXCLUDED.urls, tweets0x001.urls),
+				media = COALESCE(EXCLUDED.media, tweets0x001.media),
+				like_count = GREATEST(EXCLUDED.like_count, tweets0x001.like_count),
+				retweet_count = GREATEST(EXCLUDED.retweet_count, tweets0x001.retweet_count),
+				reply_count = GREATEST(EXCLUDED.reply_count, tweets0x001.reply_count),
+				quote_count = GREATEST(EXCLUDED.quote_count, tweets0x001.quote_count),
+				fetched_at = EXCLUDED.fetched_at,
+				raw = COALESCE(EXCLUDED.raw, tweets0x001.raw)
+			RETURNING (xmax = 0)`,
+			tweet.ID, tweet.Text, createdAt, tweet.Username,
+			nullIfEmpty(tweet.ConversationID), nullIfEmpty(tweet.InReplyToID), nullIfEmpty(tweet.InReplyToUsername),
+			nullIfEmpty(tweet.QuotedID), nullIfEmpty(tweet.QuotedUsername),
+			nullIfEmpty(tweet.RetweetedID), nullIfEmpty(tweet.RetweetedUsername), nullIfEmpty(tweet.Lang),
+			nullIfNoStrings(tweet.URLs), nullIfNoStrings(tweet.Media),
+			tweet.LikeCount, tweet.RetweetCount, tweet.ReplyCount, tweet.QuoteCount,
+			fetchedAt, raw)
+	}
+
+	results := s.pool.SendBatch(ctx, batch)
+	defer results.Close()
+
+	inserted := 0
+	for _, tweet := range tweets {
+		var isNew bool
+		if err := results.QueryRow().Scan(&isNew); err != nil {
+			return inserted, fmt.Errorf("failed to upsert tweet %s: %v", tweet.ID, err)
+		}
+		if isNew {
+			inserted++
+		}
+	}
+	return inserted, nil
+}
+
+func (s *postgresStore) LoadDailyReport(ctx context.Context, accountsList string, date time.Time) (DailyReport, error) {
+	var data []byte
+	err := s.pool.QueryRow(ctx, "SELECT report FROM daily_reports WHERE accounts_list = $1 AND report_date = $2",
+		accountsList, date).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DailyReport{}, ErrReportNotFound
+	}
+	if err != nil {
+		return DailyReport{}, fmt.Errorf("failed to query daily report: %v", err)
+	}
+	var report DailyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return DailyReport{}, fmt.Errorf("failed to parse daily report: %v", err)
+	}
+	return report, nil
+}
+
+func (s *postgresStore) SaveDailyReport(ctx context.Context, accountsList string, report DailyReport) error {
+	date, err := time.Parse("2006-01-02", report.Date)
+	if err != nil {
+		return fmt.Errorf("invalid report date %q: %v", report.Date, err)
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal daily report: %v", err)
+	}
+	_, err = s.pool.Exec(ctx, `INSERT INTO daily_reports (accounts_list, report_date, report)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (accounts_list, report_date) DO UPDATE SET report = EXCLUDED.report, generated_at = now()`,
+		accountsList, date, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save daily report: %v", err)
+	}
+	return nil
+}
+
+func (s *postgresStore) LoadDailyInteractions(ctx context.Context, accountsList string, date time.Time) (DailyInteractions, error) {
+	interactions := DailyInteractions{Date: date.Format("2006-01-02")}
+	err := s.pool.QueryRow(ctx, "SELECT timezone, fingerprint FROM interaction_days WHERE accounts_list = $1 AND day = $2",
+		accountsList, date).Scan(&interactions.Timezone, &interactions.Fingerprint)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DailyInteractions{}, ErrReportNotFound
+	}
+	if err != nil {
+		return DailyInteractions{}, fmt.Errorf("failed to query interactions: %v", err)
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT source, target, kind, weight FROM interactions
+		WHERE accounts_list = $1 AND day = $2 ORDER BY source, target, kind`, accountsList, date)
+	if err != nil {
+		return DailyInteractions{}, fmt.Errorf("failed to query interactions: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var edge Interaction
+		if err := rows.Scan(&edge.Source, &edge.Target, &edge.Kind, &edge.Weight); err != nil {
+			return DailyInteractions{}, fmt.Errorf("failed to scan interaction: %v", err)
+		}
+		interactions.Edges = append(interactions.Edges, edge)
+	}
+	if err := rows.Err(); err != nil {
+		return DailyInteractions{}, fmt.Errorf("failed to read interactions: %v", err)
+	}
+	return interactions, nil
+}
+
+// SaveDailyInteractions replaces the interactions of the day in one transaction
+func (s *postgresStore) SaveDailyInteractions(ctx context.Context, accountsList string, interactions DailyInteractions) error {
+	date, err := time.Parse("2006-01-02", interactions.Date)
+	if err != nil {
+		return fmt.Errorf("invalid interactions date %q: %v", interactions.Date, err)
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `INSERT INTO interaction_days (accounts_list, day, timezone, fingerprint) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (accounts_list, day) DO UPDATE SET timezone = EXCLUDED.timezone, fingerprint = EXCLUDED.fingerprint, generated_at = now()`,
+		accountsList, date, interactions.Timezone, interactions.Fingerprint); err != nil {
+		return fmt.Errorf("failed to save interactions: %v", err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM interactions WHERE accounts_list = $1 AND day = $2", accountsList, date); err != nil {
+		return fmt.Errorf("failed to save interactions: %v", err)
+	}
+	batch := &pgx.Batch{}
+	for _, edge := range interactions.Edges {
+		batch.Queue("INSERT INTO interactions (accounts_list, day, source, target, kind, weight) VALUES ($1, $2, $3, $4, $5, $6)",
+			accountsList, date, edge.Source, edge.Target, edge.Kind, edge.Weight)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to save interactions: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit interactions: %v", err)
+	}
+	return nil
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullIfNoStrings(values []string) any {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+func scanTweet(rows pgx.Rows) (Tweet, error) {
+	var tweet Tweet
+	var date time.Time
+	var fetchedAt *time.Time
+	var raw []byte
+	err := rows.Scan(&tweet.ID, &tweet.Text, &tweet.Username, &date,
+		&tweet.ConversationID, &tweet.InReplyToID, &tweet.InReplyToUsername,
+		&tweet.QuotedID, &tweet.QuotedUsername, &tweet.RetweetedID, &tweet.RetweetedUsername,
+		&tweet.Lang, &tweet.URLs, &tweet.Media,
+		&tweet.LikeCount, &tweet.RetweetCount, &tweet.ReplyCount, &tweet.QuoteCount,
+		&fetchedAt, &raw)
+	if err != nil {
+		return Tweet{}, fmt.Errorf("failed to scan row: %v", err)
+	}
+	tweet.CreatedAt = date.UTC().Format("2006-01-02 15:04:05")
+	if fetchedAt != nil {
+		tweet.FetchedAt = fetchedAt.UTC().Format(time.RFC3339)
+	}
+	if len(raw) > 0 {
+		tweet.Raw = raw
+	}
+	return tweet, nil
+}
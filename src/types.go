@@ -1,22 +1,63 @@
 package main
 
 import (
+	"encoding/json"
 	"time"
 	"net/http"
 )
 
+// Tweet is a stored tweet. Only the first four fields are always set; the rest
+// depend on what the timeline source provided, and are empty for older rows.
 type Tweet struct {
 	ID        string `json:"tweet_id"`
 	Text      string `json:"text"`
 	CreatedAt string `json:"created_at"`
 	Username  string `json:"username"`
+
+	ConversationID    string `json:"conversation_id,omitempty"`
+	InReplyToID       string `json:"in_reply_to_id,omitempty"`
+	InReplyToUsername string `json:"in_reply_to_username,omitempty"`
+	QuotedID          string `json:"quoted_id,omitempty"`
+	QuotedUsername    string `json:"quoted_username,omitempty"`
+	RetweetedID       string `json:"retweeted_id,omitempty"`
+	RetweetedUsername string `json:"retweeted_username,omitempty"`
+	Lang              string `json:"lang,omitempty"`
+
+	URLs  []string `json:"urls,omitempty"`
+	Media []string `json:"media,omitempty"` // media URLs
+
+	LikeCount    int `json:"like_count,omitempty"`
+	RetweetCount int `json:"retweet_count,omitempty"`
+	ReplyCount   int `json:"reply_count,omitempty"`
+	QuoteCount   int `json:"quote_count,omitempty"`
+
+	FetchedAt string          `json:"fetched_at,omitempty"`
+	Raw       json.RawMessage `json:"raw,omitempty"` // the payload as the source sent it
 }
 
 type TimelineResponse struct {
 	Timeline []Tweet `json:"timeline"`
+	Next     string  `json:"next,omitempty"` // cursor of the next, older page; empty on the last one
 }
 
 type App struct {
+	store     TweetStore
+	llm       LLMProvider // nil when no LLM is configured
+	usage     *usageTracker
+	reports   ReportStore
+	refresh   bool // regenerate daily reports even when they are stored
+	location  *time.Location // report days run from midnight to midnight here
+	formats   []string       // formats multi-day reports are saved in: json, md and html
+	verify    string         // how claims are verified: off, lexical or llm
+	injection string         // what to do with tweets that look like prompt injection: off, flag or quarantine
+	accountFilter AccountFilter // which accounts of a list are reported on
+	groupBy       string        // account field multi-day reports are grouped by, if any
+	baselineDays  int           // days of history anomalies are detected against; 0 disables them
+	notifier      *Notifier
+	classifier    *ClassifierModel // scores how agent-like accounts are
+	model     string      // LLM model used for summaries
+	outputDir string      // directory where reports are written
+	workers   int         // days and accounts processed in parallel
 	/*
 	screen         tcell.Screen
 	tweets         []Tweet
@@ -31,6 +72,10 @@ type App struct {
 
 type Fetcher struct {
 	client     *http.Client
-	rateLimiter chan time.Time
+	rateLimiter chan time.Time // token bucket, refilled every interval
 	accountsList string
+	source     TimelineSource
+	store      TweetStore
+	interval   time.Duration
+	lookback   time.Duration // how far back to fetch accounts with no stored tweets
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Verification modes for the claims of account summaries
+const (
+	verifyOff     = "off"
+	verifyLexical = "lexical" // claim words found in the tweets
+	verifyLLM     = "llm"     // a second LLM pass, with the lexical check as fallback
+)
+
+// Claim statuses after verification
+const (
+	claimSupported   = "supported"
+	claimUnsupported = "unsupported"
+)
+
+// lexicalSupportThreshold is the share of a claim's content words that must appear in its
+// tweets for the lexical check to count it as supported
+const lexicalSupportThreshold = 0.5
+
+// stopwords are left out of the lexical check, since every text has them
+var stopwords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true, "you": true,
+	"all": true, "any": true, "can": true, "has": true, "have": true, "had": true, "was": true,
+	"were": true, "with": true, "this": true, "that": true, "these": true, "those": true,
+	"from": true, "they": true, "their": true, "them": true, "its": true, "his": true, "her": true,
+	"about": true, "into": true, "over": true, "also": true, "more": true, "most": true,
+	"such": true, "than": true, "then": true, "there": true, "what": true, "which": true,
+	"who": true, "will": true, "would": true, "been": true, "being": true, "other": true,
+	"some": true, "very": true, "our": true, "out": true, "how": true, "why": true,
+	"account": true, "tweet": true, "tweets": true, "tweeted": true, "posted": true, "post": true,
+	"posts": true, "discussed": true, "discusses": true, "mentioned": true, "mentions": true,
+	"shared": true, "shares": true, "highlighted": true, "highlights": true, "notes": true,
+	"noted": true, "user": true, "users": true,
+}
+
+// verifyClaims sets the status of each claim, by checking it against the tweets it cites,
+// or against all of tweets if it cites none. In llm mode the LLM decides, and if it fails,
+// the lexical statuses are kept and the error returned.
+func (a *App) verifyClaims(ctx context.Context, claims []Claim, tweets []Tweet) error {
+	byID := make(map[string]Tweet)
+	for _, tweet := range tweets {
+		byID[tweet.ID] = tweet
+	}
+	sources := func(claim Claim) []Tweet {
+		var cited []Tweet
+		for _, id := range claim.TweetIDs {
+			cited = append(cited, byID[id])
+		}
+		if len(cited) == 0 {
+			return tweets
+		}
+		return cited
+	}
+
+	for i := range claims {
+		score := lexicalSupport(claims[i].Text, sources(claims[i]))
+		claims[i].Support = score
+		claims[i].VerifiedBy = verifyLexical
+		claims[i].Status = claimUnsupported
+		if score >= lexicalSupportThreshold {
+			claims[i].Status = claimSupported
+		}
+	}
+	if a.verify != verifyLLM || a.llm == nil || len(claims) == 0 {
+		return nil
+	}
+
+	texts := make([]string, len(claims))
+	for i, claim := range claims {
+		texts[i] = claim.Text
+	}
+	lines := make([]string, 0, len(tweets))
+	for _, tweet := range tweets {
+		lines = append(lines, formatCitedLine([]string{tweet.ID}, tweet.Text))
+	}
+	// Verification only needs the tweets, not a summary of them, so a day too long for
+	// the context keeps the lexical statuses
+	text := strings.Join(lines, "\n")
+	if estimateTokens(text) > inputTokenBudget(a.model) {
+		return fmt.Errorf("tweets too long to verify claims with %s", a.model)
+	}
+
+	verdicts, err := CheckClaims(ctx, a.llm, a.model, texts, text)
+	if err != nil {
+		return err
+	}
+	for _, verdict := range verdicts {
+		i := verdict.Claim - 1
+		if i < 0 || i >= len(claims) {
+			continue
+		}
+		claims[i].VerifiedBy = verifyLLM
+		claims[i].Reason = verdict.Reason
+		claims[i].Status = claimUnsupported
+		if verdict.Supported {
+			claims[i].Status = claimSupported
+		}
+	}
+	return nil
+}
+
+// lexicalSupport is the share of the content words of claim that appear in tweets
+func lexicalSupport(claim string, tweets []Tweet) float64 {
+	words := contentWords(claim)
+	if len(words) == 0 {
+		return 1
+	}
+	found := make(map[string]bool)
+	for _, tweet := range tweets {
+		for _, word := range contentWords(tweet.Text) {
+			found[word] = true
+		}
+	}
+	matched := 0
+	for _, word := range words {
+		if found[word] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(words))
+}
+
+// contentWords splits text into lowercase, roughly stemmed words, without stopwords
+// and words shorter than three characters
+func contentWords(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var words []string
+	for _, field := range fields {
+		if len([]rune(field)) < 3 || stopwords[field] {
+			continue
+		}
+		words = append(words, stem(field))
+	}
+	return words
+}
+
+// stem strips a few English suffixes, so that "passed" matches "passes"
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "ed", "es", "s"} {
+		if len(word) > len(suffix)+3 && strings.HasSuffix(word, suffix) {
+			return strings.TrimSuffix(word, suffix)
+		}
+	}
+	return word
+}